@@ -16,12 +16,10 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/cellpointmobile/vk/program"
 
-	"github.com/cellpointmobile/vk/programs"
 	"github.com/spf13/cobra"
 )
 
@@ -31,27 +29,43 @@ var availableCmd = &cobra.Command{
 	Short: "List tools available for install",
 	Long:  `Lists all available tools that are not already installed.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		all, _ := cmd.Flags().GetBool("all")
 		keys := make([]string, 0, len(progs))
 		for k := range progs {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
+		if isStructuredOutput() {
+			var tools []toolInfo
+			for _, k := range keys {
+				if all || !progs[k].IsInstalled() {
+					tools = append(tools, getToolInfo(progs[k]))
+				}
+			}
+			printTools(tools)
+			return
+		}
 		for _, k := range keys {
 			prog := progs[k]
 			if all {
 				v, _, err := prog.GetLatestVersion()
 				if err != nil {
-					fmt.Fprintln(os.Stderr, "Can't get latest version.")
-					os.Exit(10)
+					exitWithError(err)
 				}
 				fmt.Printf("%s version %s", prog.GetCmd(), v)
 				if prog.IsInstalled() {
-					if program.IsLatestVersion(prog) {
+					latest, err := program.IsLatestVersion(prog)
+					if err != nil {
+						exitWithError(err)
+					}
+					if latest {
 						fmt.Printf(" (installed)")
 					} else {
-						lv := prog.GetLocalVersion()
+						lv, err := prog.GetLocalVersion()
+						if err != nil {
+							exitWithError(err)
+						}
 						fmt.Printf(" (%s installed)", lv)
 					}
 				}
@@ -60,8 +74,7 @@ var availableCmd = &cobra.Command{
 				if !prog.IsInstalled() {
 					v, _, err := prog.GetLatestVersion()
 					if err != nil {
-						fmt.Fprintln(os.Stderr, "Can't get latest version.")
-						os.Exit(10)
+						exitWithError(err)
 					}
 					fmt.Printf("%s version %s\n", prog.GetCmd(), v)
 				}
@@ -16,35 +16,55 @@ package cmd
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"sort"
 
+	"github.com/cellpointmobile/vk/file"
 	"github.com/cellpointmobile/vk/program"
 
-	"github.com/cellpointmobile/vk/programs"
 	"github.com/spf13/cobra"
 )
 
+// debugToolInfo returns the tool info of a program, including the status code of its download URL
+func debugToolInfo(p program.IProgram) toolInfo {
+	t := getToolInfo(p)
+	if t.DownloadURL != "" {
+		// Requests are authorized like downloads, ex: signed for S3
+		resp, err := file.Get(t.DownloadURL, nil)
+		if err != nil {
+			t.addError(err)
+			return t
+		}
+		resp.Body.Close()
+		t.DownloadStatus = resp.StatusCode
+	}
+	return t
+}
+
 func debugProgram(p program.IProgram) {
 	fmt.Printf("Debugging tool %s\n", p.GetCmd())
 	fmt.Printf("Struct: %#v\n", p)
 	isInstalled := p.IsInstalled()
 	fmt.Printf("Is installed: %t\n", isInstalled)
 	if isInstalled {
-		fmt.Printf("Local version: %s\n", p.GetLocalVersion())
+		lv, err := p.GetLocalVersion()
+		if err != nil {
+			fmt.Printf("Local version: %s\n", err)
+		} else {
+			fmt.Printf("Local version: %s\n", lv)
+		}
 	}
 	v, url, err := p.GetLatestVersion()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't get latest version.")
-		os.Exit(10)
+		exitWithError(err)
 	}
 	fmt.Printf("Latest version: %s\n", v)
-	resp, err := http.Get(url)
+	resp, err := file.Get(url, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Something went wrong with the HTTP client: %s\n", err)
-		os.Exit(20)
+		os.Exit(exitHTTP)
 	}
+	resp.Body.Close()
 	if resp.StatusCode == 200 {
 		fmt.Printf("Download URL: %s\n", url)
 	} else {
@@ -59,20 +79,32 @@ var debugCmd = &cobra.Command{
 	Long:  `This subcommand debugs a tool definition.`,
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		if len(args) == 0 {
 			keys := make([]string, 0, len(progs))
 			for k := range progs {
 				keys = append(keys, k)
 			}
 			sort.Strings(keys)
+			if isStructuredOutput() {
+				var tools []toolInfo
+				for _, k := range keys {
+					tools = append(tools, debugToolInfo(progs[k]))
+				}
+				printTools(tools)
+				return
+			}
 			for _, k := range keys {
 				debugProgram(progs[k])
 			}
 		} else {
 			progname := args[0]
 			if prog, ok := progs[progname]; ok {
-				debugProgram(prog)
+				if isStructuredOutput() {
+					printTools([]toolInfo{debugToolInfo(prog)})
+				} else {
+					debugProgram(prog)
+				}
 			} else {
 				fmt.Fprintf(os.Stderr, "Unknown program: %s\n", progname)
 			}
@@ -0,0 +1,52 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/cellpointmobile/vk/internal/testprogram"
+)
+
+// authorizedProgram is a fake program whose downloads must be authorized, like
+// those of S3 and OCI registries
+type authorizedProgram struct {
+	*testprogram.Program
+	url string
+}
+
+func (p *authorizedProgram) GetLatestVersion() (string, string, error) {
+	file.SetAuthorizer(p.url, func(r *http.Request) error {
+		r.Header.Set("Authorization", "secret")
+		return nil
+	})
+	return "1.0.0", p.url + "fake", nil
+}
+
+func TestDebugToolInfo(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer s.Close()
+	info := debugToolInfo(&authorizedProgram{newFakeProgram(t), s.URL + "/"})
+	if info.DownloadStatus != http.StatusOK || info.Error != "" {
+		t.Errorf("download status is %d, %s, expected %d", info.DownloadStatus, info.Error, http.StatusOK)
+	}
+}
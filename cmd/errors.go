@@ -0,0 +1,99 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/cellpointmobile/vk/program"
+	"github.com/cellpointmobile/vk/programs"
+	"github.com/cellpointmobile/vk/store"
+)
+
+// Exit codes of vk. These are documented in the README.
+const (
+	exitError               = 1
+	exitVersionLookup       = 10
+	exitVersionNotFound     = 11
+	exitHTTP                = 20
+	exitRateLimited         = 30
+	exitDefinitionsDownload = 40
+	exitDefinitionsParse    = 50
+	exitVersionParse        = 60
+	exitDownload            = 70
+	exitInstall             = 80
+	exitExtract             = 90
+	exitDefinitionsLoad     = 120
+	exitManifest            = 130
+	exitSync                = 140
+	exitLock                = 150
+	exitChecksum            = 160
+	exitSignature           = 170
+	exitUpdate              = 180
+	exitRollback            = 190
+	exitNotStored           = 191
+	exitAssetNotFound       = 200
+)
+
+// exitCodes maps kinds of errors to exit codes. More specific errors come first.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{file.ErrInvalidSignature, exitSignature},
+	{file.ErrChecksumMismatch, exitChecksum},
+	{program.ErrRateLimited, exitRateLimited},
+	{program.ErrVersionLookup, exitVersionLookup},
+	{program.ErrVersionNotFound, exitVersionNotFound},
+	{program.ErrVersionParse, exitVersionParse},
+	{program.ErrAssetNotFound, exitAssetNotFound},
+	{program.ErrChecksum, exitChecksum},
+	{program.ErrDownload, exitDownload},
+	{program.ErrExtract, exitExtract},
+	{program.ErrInstall, exitInstall},
+	{programs.ErrDefinitionsDownload, exitDefinitionsDownload},
+	{programs.ErrDefinitionsLoad, exitDefinitionsLoad},
+	{programs.ErrDefinitionsParse, exitDefinitionsParse},
+	{store.ErrNoPreviousVersion, exitRollback},
+	{store.ErrNotStored, exitNotStored},
+}
+
+// exitCode returns the exit code for an error
+func exitCode(err error) int {
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return exitError
+}
+
+// exitWithError prints the error and exits with its exit code
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(exitCode(err))
+}
+
+// loadPrograms loads the definitions and exits if they can't be loaded
+func loadPrograms(bindir string) map[string]program.IProgram {
+	progs, err := programs.LoadPrograms(bindir)
+	if err != nil {
+		exitWithError(err)
+	}
+	return progs
+}
@@ -0,0 +1,96 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/cellpointmobile/vk/programs"
+	"github.com/cellpointmobile/vk/progress"
+	"github.com/cellpointmobile/vk/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec tool [args...]",
+	Short: "Run the version of a tool selected for the current directory.",
+	Long: `Run the version of a tool selected by the nearest .vk-version file in the
+current directory or its parents. For terraform, .terraform-version files are
+also used. If no file selects a version, the version last chosen with vk use
+or vk install is run. Versions not in the store are installed on demand.
+
+This is what the shims in the bindir run, when the shims config is enabled.`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// The output of exec is the output of the tool
+		progress.Enabled = false
+		progname := args[0]
+		dir, err := os.Getwd()
+		if err != nil {
+			exitWithError(err)
+		}
+		version, _ := store.SelectVersion(dir, progname)
+		if version == "" {
+			version = store.ActiveVersion(progname)
+		}
+		if version == "" {
+			fmt.Fprintf(os.Stderr, "No version of %s selected, add it to a %s file or run: vk use %s@<version>\n",
+				progname, store.VersionFile, progname)
+			os.Exit(exitError)
+		}
+		if err := store.CheckVersion(version); err != nil {
+			exitWithError(err)
+		}
+		v := storedVersion(progname, version)
+		if v == "" {
+			if v, err = installToStore(progname, version); err != nil {
+				exitWithError(err)
+			}
+		}
+		path, err := store.GetPath(progname, v)
+		if err != nil {
+			exitWithError(err)
+		}
+		if err := syscall.Exec(path, append([]string{progname}, args[1:]...), os.Environ()); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+// installToStore installs a version of a tool into the store, without
+// changing the version in use
+func installToStore(progname string, version string) (string, error) {
+	progs, err := programs.LoadPrograms(viper.GetString("bindir"))
+	if err != nil {
+		return "", err
+	}
+	prog, ok := progs[progname]
+	if !ok {
+		return "", fmt.Errorf("unknown program: %s", progname)
+	}
+	fmt.Fprintf(os.Stderr, "Installing %s version %s.\n", progname, version)
+	return store.Add(prog, func() (string, error) {
+		return prog.DownloadVersion(version)
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+}
@@ -16,23 +16,48 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
-	"github.com/cellpointmobile/vk/programs"
+	"github.com/cellpointmobile/vk/manifest"
+	"github.com/cellpointmobile/vk/program"
+	"github.com/cellpointmobile/vk/store"
 	"github.com/spf13/cobra"
 )
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
-	Use:   "install",
+	Use:   "install tool[@version]",
 	Short: "Install a tool",
-	Long:  `Install latest version of the given tool.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Install latest version of the given tool.
+
+A specific version can be installed by appending it to the tool name,
+ex: vk install helm@3.2.1
+
+With --frozen only the version recorded in vk.lock is installed, and
+tools not in the lockfile are refused.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		progname := args[0]
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progname, version := splitToolVersion(args[0])
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		if prog, ok := progs[progname]; ok {
-			if !prog.IsInstalled() || force {
-				v := prog.DownloadLatestVersion()
+			if frozen {
+				installFrozen(prog, version)
+			} else if version != "" {
+				if !prog.IsInstalled() || force || !isInstalledVersion(prog, version) {
+					v, err := installVersion(prog, version)
+					if err != nil {
+						exitWithError(err)
+					}
+					fmt.Printf("%s version %s has been installed.\n", progname, v)
+				} else {
+					fmt.Printf("%s version %s is already installed.\n", progname, version)
+				}
+			} else if !prog.IsInstalled() || force {
+				v, err := installLatestVersion(prog)
+				if err != nil {
+					exitWithError(err)
+				}
 				fmt.Printf("%s version %s has been installed.\n", progname, v)
 			} else {
 				fmt.Printf("%s is already installed.\n", progname)
@@ -43,7 +68,67 @@ var installCmd = &cobra.Command{
 	},
 }
 
+// installLatestVersion installs the latest version of a program into the store and activates it
+func installLatestVersion(prog program.IProgram) (string, error) {
+	return store.Install(prog, prog.DownloadLatestVersion)
+}
+
+// installVersion installs the given version of a program into the store and activates it
+func installVersion(prog program.IProgram, version string) (string, error) {
+	return store.Install(prog, func() (string, error) {
+		return prog.DownloadVersion(version)
+	})
+}
+
+// isInstalledVersion returns true if the given version of the program is installed
+func isInstalledVersion(prog program.IProgram, version string) bool {
+	lv, err := prog.GetLocalVersion()
+	return err == nil && lv == strings.TrimPrefix(version, "v")
+}
+
+// installFrozen installs the version of a program recorded in the lockfile
+func installFrozen(prog program.IProgram, version string) {
+	lf := lockFile
+	if lf == "" {
+		lf = "vk.lock"
+	}
+	lock, err := manifest.LoadLock(lf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading lockfile: %s\n", err)
+		os.Exit(exitLock)
+	}
+	locked, ok := lock[prog.GetCmd()]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s is not in lockfile %s\n", prog.GetCmd(), lf)
+		os.Exit(exitLock)
+	}
+	if version != "" && strings.TrimPrefix(version, "v") != locked.Version {
+		fmt.Fprintf(os.Stderr, "%s: version %s does not match locked version %s\n", prog.GetCmd(), version, locked.Version)
+		os.Exit(exitLock)
+	}
+	if isLocked(prog, locked) && !force {
+		fmt.Printf("%s version %s is already installed.\n", prog.GetCmd(), locked.Version)
+		return
+	}
+	if err := installLocked(prog, locked); err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("%s version %s has been installed.\n", prog.GetCmd(), locked.Version)
+}
+
+// splitToolVersion splits an argument like helm@3.2.1 into tool name and version.
+// Version is empty if none is given.
+func splitToolVersion(arg string) (string, string) {
+	i := strings.LastIndex(arg, "@")
+	if i < 0 {
+		return arg, ""
+	}
+	return arg[:i], arg[i+1:]
+}
+
 func init() {
 	rootCmd.AddCommand(installCmd)
+	installCmd.Flags().BoolVar(&frozen, "frozen", false, "Only install the version recorded in the lockfile.")
+	installCmd.Flags().StringVar(&lockFile, "lock", "", "Path to lockfile used with --frozen. Defaults to vk.lock.")
 	installCmd.Flags().BoolVar(&force, "force", false, "Force installation of tool, overwriting installed version.")
 }
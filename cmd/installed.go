@@ -16,9 +16,12 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strings"
 
-	"github.com/cellpointmobile/vk/programs"
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/store"
 	"github.com/spf13/cobra"
 )
 
@@ -26,24 +29,67 @@ import (
 var installedCmd = &cobra.Command{
 	Use:   "installed",
 	Short: "List all installed tools",
-	Long:  `Output a list of installed tools with their versions`,
+	Long: `Output a list of installed tools with their versions. All versions in the
+store are listed, and the version in use is marked as active.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("The following programs are installed:")
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		keys := make([]string, 0, len(progs))
 		for k := range progs {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
+		if isStructuredOutput() {
+			var tools []toolInfo
+			for _, k := range keys {
+				if progs[k].IsInstalled() {
+					tools = append(tools, getToolInfo(progs[k]))
+				}
+			}
+			printTools(tools)
+			return
+		}
+		fmt.Println("The following programs are installed:")
 		for _, k := range keys {
 			prog := progs[k]
 			if prog.IsInstalled() {
-				fmt.Printf("%s: %s\n", prog.GetCmd(), prog.GetLocalVersion())
+				lv, err := prog.GetLocalVersion()
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				versions := []string{lv}
+				if active := store.Active(prog); active != "" {
+					versions = nil
+					for _, v := range storedVersions(prog.GetCmd()) {
+						if v == active {
+							v += " (active)"
+						}
+						versions = append(versions, v)
+					}
+				}
+				fmt.Printf("%s: %s\n", prog.GetCmd(), strings.Join(versions, ", "))
 			}
 		}
 	},
 }
 
+// storedVersions returns the stored versions of a command, newest version first
+func storedVersions(cmd string) []string {
+	versions, err := store.Versions(cmd)
+	if err != nil {
+		return nil
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, erri := semver.NewVersion(versions[i])
+		vj, errj := semver.NewVersion(versions[j])
+		if erri != nil || errj != nil {
+			return versions[i] > versions[j]
+		}
+		return vj.LessThan(vi)
+	})
+	return versions
+}
+
 func init() {
 	rootCmd.AddCommand(installedCmd)
 }
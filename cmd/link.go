@@ -0,0 +1,71 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cellpointmobile/vk/store"
+	"github.com/spf13/cobra"
+)
+
+// linkCmd represents the link command
+var linkCmd = &cobra.Command{
+	Use:   "link [tool]",
+	Short: "Recreate the tools in the bindir as symlinks or shims.",
+	Long: `Recreate the bindir entries of installed tools. With the shims config
+enabled, the tools become shims running vk exec, otherwise they become
+symlinks to the version in use. Run this after changing the shims config.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
+		var keys []string
+		if len(args) == 1 {
+			if _, ok := progs[args[0]]; !ok {
+				fmt.Fprintf(os.Stderr, "Unknown program: %s\n", args[0])
+				os.Exit(exitError)
+			}
+			keys = args
+		} else {
+			for k := range progs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+		}
+		for _, k := range keys {
+			prog := progs[k]
+			if !prog.IsInstalled() {
+				continue
+			}
+			if err := store.Adopt(prog); err != nil {
+				exitWithError(err)
+			}
+			v := store.Active(prog)
+			if v == "" {
+				continue
+			}
+			if err := store.Use(prog, v); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Linked %s version %s.\n", k, v)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(linkCmd)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/cellpointmobile/vk/manifest"
+	"github.com/cellpointmobile/vk/program"
+	"github.com/cellpointmobile/vk/store"
+)
+
+var (
+	frozen   bool
+	lockFile string
+)
+
+// lockProgram returns a lockfile entry for the given installed version of a program
+func lockProgram(prog program.IProgram, version string) (manifest.LockedTool, error) {
+	v, url, err := prog.GetVersion(version)
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	sum, err := file.SHA256(prog.GetExecutable())
+	if err != nil {
+		return manifest.LockedTool{}, err
+	}
+	return manifest.LockedTool{Version: v, URL: url, SHA256: sum}, nil
+}
+
+// isLocked returns true if the installed program is the exact binary recorded in the lockfile
+func isLocked(prog program.IProgram, locked manifest.LockedTool) bool {
+	if !prog.IsInstalled() {
+		return false
+	}
+	sum, err := file.SHA256(prog.GetExecutable())
+	return err == nil && sum == locked.SHA256
+}
+
+// installLocked installs the version recorded in the lockfile. Its digest is
+// verified before it is used, so the installed version is kept on a mismatch.
+func installLocked(prog program.IProgram, locked manifest.LockedTool) error {
+	_, url, err := prog.GetVersion(locked.Version)
+	if err != nil {
+		return err
+	}
+	if url != locked.URL {
+		return fmt.Errorf("%s: download URL %s does not match locked URL %s", prog.GetCmd(), url, locked.URL)
+	}
+	_, err = store.Install(prog, func() (string, error) {
+		v, err := prog.DownloadVersion(locked.Version)
+		if err != nil {
+			return "", err
+		}
+		// The download is in a staging directory until it is verified
+		sum, err := file.SHA256(prog.GetFullPath())
+		if err != nil {
+			return "", err
+		}
+		if sum != locked.SHA256 {
+			return "", fmt.Errorf("%s: %w: %s does not match locked %s", prog.GetCmd(), file.ErrChecksumMismatch, sum, locked.SHA256)
+		}
+		return v, nil
+	})
+	return err
+}
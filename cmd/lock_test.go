@@ -0,0 +1,95 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/cellpointmobile/vk/internal/testprogram"
+	"github.com/cellpointmobile/vk/manifest"
+	"github.com/cellpointmobile/vk/store"
+)
+
+// newFakeProgram returns a fake program, with the store in its HOME directory
+func newFakeProgram(t *testing.T) *testprogram.Program {
+	t.Helper()
+	p := testprogram.New(t)
+	store.Dir = filepath.Join(filepath.Dir(p.Path), ".vk", "store")
+	return p
+}
+
+func TestInstallLocked(t *testing.T) {
+	p := newFakeProgram(t)
+	if _, err := installVersion(p, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := lockProgram(p, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = installVersion(p, "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if isLocked(p, locked) {
+		t.Error("version 2.0.0 matches the lockfile of version 1.0.0")
+	}
+	if err = installLocked(p, locked); err != nil {
+		t.Fatal(err)
+	}
+	if !isLocked(p, locked) {
+		t.Error("installed version does not match the lockfile")
+	}
+}
+
+func TestInstallLockedMismatch(t *testing.T) {
+	p := newFakeProgram(t)
+	if _, err := installVersion(p, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	locked := manifest.LockedTool{
+		Version: "2.0.0",
+		URL:     "https://example.com/fake/2.0.0",
+		SHA256:  "0000000000000000000000000000000000000000000000000000000000000000",
+	}
+	err := installLocked(p, locked)
+	if !errors.Is(err, file.ErrChecksumMismatch) {
+		t.Fatalf("installLocked returned %v, expected %v", err, file.ErrChecksumMismatch)
+	}
+	// The installed version is kept
+	lv, err := p.GetLocalVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv != "1.0.0" {
+		t.Errorf("local version is %s after a mismatch, expected 1.0.0", lv)
+	}
+	if store.IsStored("fake", "2.0.0") {
+		t.Error("mismatching version 2.0.0 is in the store")
+	}
+}
+
+func TestInstallLockedURLMismatch(t *testing.T) {
+	p := newFakeProgram(t)
+	locked := manifest.LockedTool{Version: "1.0.0", URL: "https://example.com/other/1.0.0"}
+	if err := installLocked(p, locked); err == nil {
+		t.Error("installLocked succeeded with a different download URL")
+	}
+	if p.IsInstalled() {
+		t.Error("program is installed from a different download URL")
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/program"
+	"github.com/cellpointmobile/vk/store"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// toolInfo describes a tool in json or yaml output
+type toolInfo struct {
+	Name             string   `json:"name" yaml:"name"`
+	InstalledVersion string   `json:"installed_version,omitempty" yaml:"installed_version,omitempty"`
+	ActiveVersion    string   `json:"active_version,omitempty" yaml:"active_version,omitempty"`
+	LatestVersion    string   `json:"latest_version,omitempty" yaml:"latest_version,omitempty"`
+	UpdateAvailable  bool     `json:"update_available" yaml:"update_available"`
+	DownloadURL      string   `json:"download_url,omitempty" yaml:"download_url,omitempty"`
+	DownloadStatus   int      `json:"download_status,omitempty" yaml:"download_status,omitempty"`
+	Source           string   `json:"source" yaml:"source"`
+	StoredVersions   []string `json:"stored_versions,omitempty" yaml:"stored_versions,omitempty"`
+	Path             string   `json:"path" yaml:"path"`
+	Error            string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// isStructuredOutput returns true if output should be json or yaml
+func isStructuredOutput() bool {
+	return output == "json" || output == "yaml"
+}
+
+// getToolInfo looks up the installed and latest version of a tool.
+// Errors are recorded in the result instead of being returned.
+func getToolInfo(p program.IProgram) toolInfo {
+	t := toolInfo{
+		Name:   p.GetCmd(),
+		Source: p.GetSource(),
+		Path:   p.GetFullPath(),
+	}
+	if p.IsInstalled() {
+		lv, err := p.GetLocalVersion()
+		if err != nil {
+			t.addError(err)
+		}
+		t.InstalledVersion = lv
+		t.ActiveVersion = store.Active(p)
+		t.StoredVersions = storedVersions(p.GetCmd())
+	}
+	v, url, err := p.GetLatestVersion()
+	if err != nil {
+		t.addError(err)
+		return t
+	}
+	t.LatestVersion = v
+	t.DownloadURL = url
+	if t.InstalledVersion != "" {
+		lv, err := semver.NewVersion(t.InstalledVersion)
+		if err != nil {
+			t.addError(err)
+			return t
+		}
+		nv, err := semver.NewVersion(v)
+		if err != nil {
+			t.addError(err)
+			return t
+		}
+		t.UpdateAvailable = lv.LessThan(nv)
+	}
+	return t
+}
+
+// addError records an error, after any error recorded before
+func (t *toolInfo) addError(err error) {
+	if t.Error != "" {
+		t.Error += "; "
+	}
+	t.Error += err.Error()
+}
+
+// printTools prints tools in the format given by the output flag
+func printTools(tools []toolInfo) {
+	d, err := formatTools(tools)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting output: %s\n", err)
+		os.Exit(exitError)
+	}
+	os.Stdout.Write(d)
+}
+
+// formatTools formats tools as json or yaml, as given by the output flag
+func formatTools(tools []toolInfo) ([]byte, error) {
+	if tools == nil {
+		tools = []toolInfo{}
+	}
+	switch output {
+	case "json":
+		d, err := json.MarshalIndent(tools, "", "  ")
+		return append(d, '\n'), err
+	case "yaml":
+		return yaml.Marshal(tools)
+	}
+	return nil, fmt.Errorf("unknown output format: %s", output)
+}
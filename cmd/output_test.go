@@ -0,0 +1,173 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cellpointmobile/vk/internal/testprogram"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// releasedProgram is a fake program with a latest release
+type releasedProgram struct {
+	*testprogram.Program
+	latest string
+	err    error
+}
+
+func (p *releasedProgram) GetLatestVersion() (string, string, error) {
+	if p.err != nil {
+		return "", "", p.err
+	}
+	return p.latest, "https://example.com/fake/" + p.latest, nil
+}
+
+func TestGetToolInfo(t *testing.T) {
+	lookupErr := errors.New("lookup failed")
+	for _, tc := range []struct {
+		name      string
+		installed string // Installed version, "broken" for an unreadable binary
+		err       error
+		expected  toolInfo
+	}{
+		{
+			name:      "update available",
+			installed: "1.0.0",
+			expected: toolInfo{
+				InstalledVersion: "1.0.0",
+				ActiveVersion:    "1.0.0",
+				LatestVersion:    "2.0.0",
+				UpdateAvailable:  true,
+				DownloadURL:      "https://example.com/fake/2.0.0",
+				StoredVersions:   []string{"1.0.0"},
+			},
+		},
+		{
+			name:      "latest version",
+			installed: "2.0.0",
+			expected: toolInfo{
+				InstalledVersion: "2.0.0",
+				ActiveVersion:    "2.0.0",
+				LatestVersion:    "2.0.0",
+				DownloadURL:      "https://example.com/fake/2.0.0",
+				StoredVersions:   []string{"2.0.0"},
+			},
+		},
+		{
+			name: "not installed",
+			expected: toolInfo{
+				LatestVersion: "2.0.0",
+				DownloadURL:   "https://example.com/fake/2.0.0",
+			},
+		},
+		{
+			name:      "broken binary",
+			installed: "broken",
+			expected: toolInfo{
+				LatestVersion: "2.0.0",
+				DownloadURL:   "https://example.com/fake/2.0.0",
+				Error:         "fake: can't parse version",
+			},
+		},
+		{
+			name:      "lookup failed",
+			installed: "1.0.0",
+			err:       lookupErr,
+			expected: toolInfo{
+				InstalledVersion: "1.0.0",
+				ActiveVersion:    "1.0.0",
+				StoredVersions:   []string{"1.0.0"},
+				Error:            "lookup failed",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &releasedProgram{newFakeProgram(t), "2.0.0", tc.err}
+			switch tc.installed {
+			case "":
+			case "broken":
+				if err := ioutil.WriteFile(p.GetFullPath(), []byte("\x7fELF"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			default:
+				if _, err := installVersion(p, tc.installed); err != nil {
+					t.Fatal(err)
+				}
+			}
+			info := getToolInfo(p)
+			if !strings.HasPrefix(info.Error, tc.expected.Error) || (info.Error == "") != (tc.expected.Error == "") {
+				t.Errorf("error is %q, expected %q", info.Error, tc.expected.Error)
+			}
+			tc.expected.Name = "fake"
+			tc.expected.Source = "fake"
+			tc.expected.Path = p.GetFullPath()
+			tc.expected.Error = info.Error
+			if !reflect.DeepEqual(info, tc.expected) {
+				t.Errorf("getToolInfo() = %+v, expected %+v", info, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFormatTools(t *testing.T) {
+	defer func(o string) { output = o }(output)
+	tools := []toolInfo{{
+		Name:             "fake",
+		InstalledVersion: "1.0.0",
+		ActiveVersion:    "1.0.0",
+		LatestVersion:    "2.0.0",
+		UpdateAvailable:  true,
+		Source:           "fake",
+		Path:             "/bin/fake",
+	}}
+	for _, tc := range []struct {
+		output    string
+		unmarshal func([]byte, interface{}) error
+	}{
+		{"json", json.Unmarshal},
+		{"yaml", yaml.Unmarshal},
+	} {
+		t.Run(tc.output, func(t *testing.T) {
+			output = tc.output
+			d, err := formatTools(tools)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields []map[string]interface{}
+			if err = tc.unmarshal(d, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if len(fields) != 1 || fields[0]["active_version"] != "1.0.0" || fields[0]["update_available"] != true {
+				t.Errorf("formatted tools are %s", d)
+			}
+			if _, ok := fields[0]["error"]; ok {
+				t.Errorf("empty error is in output: %s", d)
+			}
+			d, err = formatTools(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(d)) != "[]" {
+				t.Errorf("no tools are formatted as %q, expected an empty list", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cellpointmobile/vk/store"
+	"github.com/spf13/cobra"
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back a tool to the previously installed version.",
+	Long: `Switch the given tool back to the previously used version. vk keeps the
+last versions of each tool in ~/.vk/store, see the keep-versions config
+variable. Rolling back twice switches back to the version used before the
+first rollback.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		progname := args[0]
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
+		if prog, ok := progs[progname]; ok {
+			v, err := store.Rollback(prog)
+			if err != nil {
+				exitWithError(fmt.Errorf("%s: %w", progname, err))
+			}
+			fmt.Printf("%s has been rolled back to version %s.\n", progname, v)
+		} else {
+			fmt.Fprintf(os.Stderr, "Unknown program: %s\n", progname)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
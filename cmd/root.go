@@ -15,11 +15,18 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
 
 	glogcobra "github.com/blocktop/go-glog-cobra"
+	"github.com/cellpointmobile/vk/file"
 	"github.com/cellpointmobile/vk/program"
+	"github.com/cellpointmobile/vk/progress"
+	"github.com/cellpointmobile/vk/store"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,6 +35,7 @@ import (
 var (
 	cfgFile string
 	force   bool
+	output  string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -43,11 +51,26 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		switch output {
+		case "", "text", "json", "yaml":
+			return nil
+		}
+		return fmt.Errorf("invalid output format %s, must be one of text, json or yaml", output)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	// Interrupting vk cancels downloads, which removes their temporary files.
+	// Interrupting it again exits right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	file.Context = ctx
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -64,11 +87,19 @@ func init() {
 	rootCmd.PersistentFlags().StringP("bindir", "b", "$HOME/.local/bin", "Directory for bin-files.")
 	rootCmd.PersistentFlags().String("definitions", "", "URL/path to definitions file.")
 	rootCmd.PersistentFlags().BoolVar(&program.ClearCache, "clear-cache", false, "clear the cache.")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Output format of list commands: text, json or yaml.")
+	rootCmd.PersistentFlags().StringVar(&program.OS, "os", runtime.GOOS, "OS to install tools for.")
+	rootCmd.PersistentFlags().StringVar(&program.Arch, "arch", runtime.GOARCH, "Architecture to install tools for.")
 
 	viper.BindPFlag("bindir", rootCmd.PersistentFlags().Lookup("bindir"))
 	viper.SetDefault("bindir", "$HOME/.local/bin")
 	viper.BindPFlag("definitions", rootCmd.PersistentFlags().Lookup("definitions"))
 	viper.SetDefault("definitions", "https://raw.githubusercontent.com/cellpointmobile/vk-definitions/master/vk-definitions.json")
+	viper.SetDefault("keep-versions", store.Keep)
+	viper.SetDefault("shims", false)
+	viper.SetDefault("retries", file.Retries)
+	viper.SetDefault("connect-timeout", file.ConnectTimeout)
+	viper.SetDefault("read-timeout", file.ReadTimeout)
 
 	glogcobra.Init(rootCmd)
 }
@@ -94,6 +125,14 @@ func initConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	viper.ReadInConfig()
+	store.Keep = viper.GetInt("keep-versions")
+	store.Shims = viper.GetBool("shims")
+	file.Retries = viper.GetInt("retries")
+	file.ConnectTimeout = viper.GetDuration("connect-timeout")
+	file.ReadTimeout = viper.GetDuration("read-timeout")
+	// Progress bars are drawn on stderr, when both stderr and stdout are a
+	// terminal, so output piped to a log file has no bars in between
+	progress.Enabled = !quiet && progress.IsTerminal(os.Stdout) && progress.IsTerminal(os.Stderr)
 	// If a config file is found, read it in.
 	//if err := viper.ReadInConfig(); err == nil {
 	//	glog.Infof("Using config file: %s\n", viper.ConfigFileUsed())
@@ -0,0 +1,179 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/manifest"
+	"github.com/cellpointmobile/vk/program"
+	"github.com/spf13/cobra"
+)
+
+var manifestFile string
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Install tools listed in a vk.yaml manifest.",
+	Long: `Read the tools and version constraints listed in a vk.yaml manifest and
+install, upgrade or downgrade each tool until it matches its constraint.
+
+The resolved versions are written to vk.lock next to the manifest, together
+with their download URLs and SHA-256 digests. With --frozen the versions in
+vk.lock are installed instead, and their digests verified.
+
+Example vk.yaml:
+
+terraform: "~1.5"
+helm: ">=3.10 <4"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := manifest.Load(manifestFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading manifest: %s\n", err)
+			os.Exit(exitManifest)
+		}
+		lf := lockFile
+		if lf == "" {
+			lf = filepath.Join(filepath.Dir(manifestFile), "vk.lock")
+		}
+		// Tools that fail to sync keep their entries in an existing lockfile
+		lock, err := manifest.LoadLock(lf)
+		if err != nil {
+			if frozen || !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error loading lockfile: %s\n", err)
+				os.Exit(exitLock)
+			}
+			lock = make(manifest.Lock)
+		}
+		if !frozen {
+			// Tools removed from the manifest are removed from the lockfile
+			for progname := range lock {
+				if _, ok := m[progname]; !ok {
+					delete(lock, progname)
+				}
+			}
+		}
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
+		failed := false
+		for _, progname := range m.Tools() {
+			constraint := m[progname]
+			prog, ok := progs[progname]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Unknown program: %s\n", progname)
+				failed = true
+				continue
+			}
+			var lv string
+			if prog.IsInstalled() {
+				// A local version that can't be read is replaced like a missing one
+				lv, _ = prog.GetLocalVersion()
+			}
+			if frozen {
+				locked, ok := lock[progname]
+				if !ok {
+					fmt.Fprintf(os.Stderr, "%s is not in lockfile %s\n", progname, lf)
+					failed = true
+					continue
+				}
+				if isLocked(prog, locked) && !force {
+					if !quiet {
+						fmt.Printf("%s version %s matches lockfile.\n", progname, lv)
+					}
+					continue
+				}
+				if err := installLocked(prog, locked); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+					continue
+				}
+				if !quiet {
+					fmt.Printf("%s %s to version %s\n", syncAction(lv, locked.Version), progname, locked.Version)
+				}
+				continue
+			}
+			v := lv
+			if ok, err := program.MatchesConstraint(lv, constraint); err == nil && ok && !force {
+				if !quiet {
+					fmt.Printf("%s version %s matches %s.\n", progname, lv, constraint)
+				}
+			} else {
+				v, err = program.FindVersion(prog, constraint)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+					continue
+				}
+				v, err = installVersion(prog, v)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+					continue
+				}
+				if !quiet {
+					fmt.Printf("%s %s to version %s\n", syncAction(lv, v), progname, v)
+				}
+			}
+			locked, err := lockProgram(prog, v)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: Can't lock version %s: %s\n", progname, v, err)
+				failed = true
+				continue
+			}
+			lock[progname] = locked
+		}
+		if !frozen {
+			if err := lock.Save(lf); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing lockfile: %s\n", err)
+				os.Exit(exitLock)
+			}
+		}
+		if failed {
+			os.Exit(exitSync)
+		}
+	},
+}
+
+// syncAction describes the change from the local version to the new version
+func syncAction(local string, latest string) string {
+	if local == "" {
+		return "Installing"
+	}
+	lv, err := semver.NewVersion(local)
+	if err != nil {
+		return "Updating"
+	}
+	nv, err := semver.NewVersion(latest)
+	if err != nil {
+		return "Updating"
+	}
+	if nv.LessThan(lv) {
+		return "Downgrading"
+	}
+	return "Upgrading"
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringVarP(&manifestFile, "file", "f", "vk.yaml", "Path to manifest file.")
+	syncCmd.Flags().BoolVar(&force, "force", false, "Force installation of tools, even if they match their constraint.")
+	syncCmd.Flags().StringVar(&lockFile, "lock", "", "Path to lockfile. Defaults to vk.lock next to the manifest.")
+	syncCmd.Flags().BoolVar(&frozen, "frozen", false, "Install the versions in the lockfile instead of resolving constraints.")
+	syncCmd.Flags().BoolVarP(&quiet, "quiet", "p", false, "Only output errors.")
+}
@@ -16,25 +16,38 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
-	"github.com/cellpointmobile/vk/programs"
+	"github.com/cellpointmobile/vk/store"
 	"github.com/spf13/cobra"
 )
 
 // uninstallCmd represents the uninstall command
 var uninstallCmd = &cobra.Command{
-	Use:   "uninstall",
+	Use:   "uninstall tool[@version]",
 	Short: "Uninstall a tool.",
-	Long:  `Uninstall the given tool.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Uninstall the given tool, including all its versions in the store.
+
+A single version can be removed from the store by appending it to the tool
+name, ex: vk uninstall helm@3.2.1`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		progname := args[0]
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progname, version := splitToolVersion(args[0])
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		if prog, ok := progs[progname]; ok {
-			if prog.IsInstalled() {
-				f := prog.GetFullPath()
-				os.Remove(f)
+			if version != "" {
+				v := storedVersion(progname, version)
+				if v == "" {
+					fmt.Printf("%s version %s is not installed.\n", progname, version)
+					return
+				}
+				if err := store.Remove(prog, v); err != nil {
+					exitWithError(err)
+				}
+				fmt.Printf("%s version %s has been uninstalled.\n", progname, v)
+			} else if prog.IsInstalled() {
+				if err := store.RemoveAll(prog); err != nil {
+					exitWithError(err)
+				}
 				fmt.Printf("%s has been uninstalled.\n", progname)
 			} else {
 				fmt.Printf("%s is not installed.\n", progname)
@@ -20,11 +20,14 @@ import (
 	"sort"
 
 	"github.com/cellpointmobile/vk/program"
-	"github.com/cellpointmobile/vk/programs"
+	"github.com/cellpointmobile/vk/progress"
 	"github.com/spf13/cobra"
 )
 
-var quiet bool
+var (
+	quiet bool
+	jobs  int
+)
 
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
@@ -34,32 +37,45 @@ var updateCmd = &cobra.Command{
 	and update if the local version is not the latest.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		progs := programs.LoadPrograms(cmd.Flag("bindir").Value.String())
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
 		if len(args) == 0 {
 			keys := make([]string, 0, len(progs))
 			for k := range progs {
 				keys = append(keys, k)
 			}
 			sort.Strings(keys)
+			var installed []program.IProgram
 			for _, k := range keys {
-				prog := progs[k]
-				if prog.IsInstalled() {
-					if !program.IsLatestVersion(prog) || force {
-						v := prog.DownloadLatestVersion()
-						if !quiet {
-							fmt.Printf("Updating %s to version %s\n", prog.GetCmd(), v)
-						}
-					}
+				if progs[k].IsInstalled() {
+					installed = append(installed, progs[k])
 				}
 			}
+			var failed []updateResult
+			for r := range updatePrograms(installed, jobs) {
+				if r.err != nil {
+					failed = append(failed, r)
+				} else if r.version != "" && !quiet {
+					// Printed above the progress bars of the tools still downloading
+					progress.Printf("Updating %s to version %s\n", r.prog.GetCmd(), r.version)
+				}
+			}
+			for _, r := range failed {
+				fmt.Fprintln(os.Stderr, r.err)
+			}
+			if len(failed) > 0 {
+				os.Exit(exitUpdate)
+			}
 		} else {
 			progname := args[0]
 			if prog, ok := progs[progname]; ok {
 				if prog.IsInstalled() {
-					if !program.IsLatestVersion(prog) || force {
-						v := prog.DownloadLatestVersion()
+					r := updateProgram(prog)
+					if r.err != nil {
+						exitWithError(r.err)
+					}
+					if r.version != "" {
 						if !quiet {
-							fmt.Printf("Updating %s to version %s\n", prog.GetCmd(), v)
+							fmt.Printf("Updating %s to version %s\n", prog.GetCmd(), r.version)
 						}
 					} else {
 						if !quiet {
@@ -76,8 +92,64 @@ var updateCmd = &cobra.Command{
 	},
 }
 
+// updateResult is the outcome of updating a single program
+type updateResult struct {
+	prog    program.IProgram
+	version string // Empty if the program was already latest version
+	err     error
+}
+
+// updatePrograms updates programs using a pool of jobs workers. Results are
+// sent in the same order as progs, as soon as they are ready.
+func updatePrograms(progs []program.IProgram, jobs int) <-chan updateResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	pending := make([]chan updateResult, len(progs))
+	for i := range pending {
+		pending[i] = make(chan updateResult, 1)
+	}
+	queue := make(chan int)
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range queue {
+				pending[i] <- updateProgram(progs[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range progs {
+			queue <- i
+		}
+		close(queue)
+	}()
+	results := make(chan updateResult)
+	go func() {
+		for _, c := range pending {
+			results <- <-c
+		}
+		close(results)
+	}()
+	return results
+}
+
+// updateProgram updates a program if it is not the latest version
+func updateProgram(prog program.IProgram) updateResult {
+	r := updateResult{prog: prog}
+	latest, err := program.IsLatestVersion(prog)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	if !latest || force {
+		r.version, r.err = installLatestVersion(prog)
+	}
+	return r
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 	updateCmd.Flags().BoolVar(&force, "force", false, "Force installation of tool, overwriting installed version.")
+	updateCmd.Flags().IntVarP(&jobs, "jobs", "j", 4, "Number of tools to check and download concurrently.")
 	updateCmd.Flags().BoolVarP(&quiet, "quiet", "p", false, "Only output errors. Makes it suitable for cronjobs.")
 }
@@ -0,0 +1,103 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cellpointmobile/vk/program"
+)
+
+// slowProgram is an installed program which takes a random time to look up
+// its latest version. It is always the latest version, so it is never downloaded.
+type slowProgram struct {
+	program.Command
+	err     error
+	running *int32
+	maxRun  *int32
+}
+
+func (p *slowProgram) IsInstalled() bool                      { return true }
+func (p *slowProgram) GetLocalVersion() (string, error)       { return "1.0.0", nil }
+func (p *slowProgram) GetSource() string                      { return "slow" }
+func (p *slowProgram) ListVersions() ([]string, error)        { return nil, nil }
+func (p *slowProgram) DownloadLatestVersion() (string, error) { return "", errors.New("not latest") }
+
+func (p *slowProgram) GetVersion(version string) (string, string, error) {
+	return "", "", errors.New("not implemented")
+}
+
+func (p *slowProgram) DownloadVersion(version string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+func (p *slowProgram) GetLatestVersion() (string, string, error) {
+	n := atomic.AddInt32(p.running, 1)
+	defer atomic.AddInt32(p.running, -1)
+	for {
+		m := atomic.LoadInt32(p.maxRun)
+		if n <= m || atomic.CompareAndSwapInt32(p.maxRun, m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+	if p.err != nil {
+		return "", "", p.err
+	}
+	return "1.0.0", "", nil
+}
+
+func TestUpdatePrograms(t *testing.T) {
+	failure := errors.New("lookup failed")
+	for _, jobs := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("jobs=%d", jobs), func(t *testing.T) {
+			var running, maxRun int32
+			progs := make([]program.IProgram, 20)
+			for i := range progs {
+				p := &slowProgram{Command: program.Command{Cmd: fmt.Sprintf("tool%d", i)}, running: &running, maxRun: &maxRun}
+				if i == 5 {
+					p.err = failure
+				}
+				progs[i] = p
+			}
+			i := 0
+			for r := range updatePrograms(progs, jobs) {
+				if r.prog != progs[i] {
+					t.Errorf("result %d is for %s, expected %s", i, r.prog.GetCmd(), progs[i].GetCmd())
+				}
+				switch {
+				case i == 5 && !errors.Is(r.err, failure):
+					t.Errorf("%s returned %v, expected %v", r.prog.GetCmd(), r.err, failure)
+				case i != 5 && r.err != nil:
+					t.Errorf("%s returned %v", r.prog.GetCmd(), r.err)
+				case r.version != "":
+					t.Errorf("%s is updated to %s, expected no update", r.prog.GetCmd(), r.version)
+				}
+				i++
+			}
+			if i != len(progs) {
+				t.Errorf("got %d results, expected %d", i, len(progs))
+			}
+			if maxRun > int32(jobs) {
+				t.Errorf("%d programs ran at once, expected at most %d", maxRun, jobs)
+			}
+		})
+	}
+}
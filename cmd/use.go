@@ -0,0 +1,75 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cellpointmobile/vk/store"
+	"github.com/spf13/cobra"
+)
+
+// useCmd represents the use command
+var useCmd = &cobra.Command{
+	Use:   "use tool@version",
+	Short: "Switch a tool to another version.",
+	Long: `Switch the given tool to another version. Every installed version of a
+tool is kept in ~/.vk/store, and the tool in the bindir links to the version
+in use. If the version is not in the store, it is installed first.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		progname, version := splitToolVersion(args[0])
+		if version == "" {
+			fmt.Fprintf(os.Stderr, "No version given, ex: vk use %s@1.2.3\n", progname)
+			os.Exit(exitError)
+		}
+		progs := loadPrograms(cmd.Flag("bindir").Value.String())
+		if prog, ok := progs[progname]; ok {
+			if err := store.Adopt(prog); err != nil {
+				exitWithError(err)
+			}
+			v := storedVersion(progname, version)
+			if v == "" {
+				var err error
+				if v, err = installVersion(prog, version); err != nil {
+					exitWithError(err)
+				}
+			} else if err := store.Use(prog, v); err != nil {
+				exitWithError(err)
+			}
+			fmt.Printf("Using %s version %s.\n", progname, v)
+		} else {
+			fmt.Fprintf(os.Stderr, "Unknown program: %s\n", progname)
+		}
+	},
+}
+
+// storedVersion returns the name the version is stored under, which may have
+// a "v" prefix. Returns an empty string if the version is not stored.
+func storedVersion(cmd string, version string) string {
+	v := strings.TrimPrefix(version, "v")
+	for _, sv := range []string{v, "v" + v} {
+		if store.IsStored(cmd, sv) {
+			return sv
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(useCmd)
+}
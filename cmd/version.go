@@ -23,10 +23,10 @@ import (
 )
 
 var (
-	json    = false
-	version = "dev"
-	commit  = "none"
-	date    = "unknown"
+	versionJSON = false
+	version     = "dev"
+	commit      = "none"
+	date        = "unknown"
 )
 
 // versionCmd represents the version command
@@ -38,7 +38,7 @@ var versionCmd = &cobra.Command{
 		var response string
 		versionOutput := goVersion.New(version, commit, date)
 
-		if json {
+		if versionJSON || output == "json" {
 			response = versionOutput.ToJSON()
 		} else {
 			response = versionOutput.ToShortened()
@@ -50,5 +50,5 @@ var versionCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
-	versionCmd.Flags().BoolVarP(&json, "json", "j", false, "Output in JSON format.")
+	versionCmd.Flags().BoolVarP(&versionJSON, "json", "j", false, "Output in JSON format.")
 }
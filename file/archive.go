@@ -17,35 +17,113 @@ package file
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
-	"strings"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// tarMagicOffset is the offset of the magic string of a POSIX or GNU tar header
+const tarMagicOffset = 257
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	tarMagic   = []byte("ustar")
 )
 
-// ExtractFromTar extracts a file from a tarball
-func ExtractFromTar(source string, target string, destination string) error {
-	resp, err := http.Get(source)
+// decompress returns a reader of the tarball in r, which is detected by its
+// magic bytes to be compressed with gzip, bzip2, xz or zstd, or uncompressed.
+// The reader must be closed to release the decompressor.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, 512)
+	// Peek returns an error if the stream is shorter, but still returns what was read
+	head, _ := br.Peek(tarMagicOffset + len(tarMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, bzip2Magic):
+		return ioutil.NopCloser(bzip2.NewReader(br)), nil
+	case bytes.HasPrefix(head, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case bytes.HasPrefix(head, zstdMagic):
+		d, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		// Closing it stops the goroutines of the decoder
+		return d.IOReadCloser(), nil
+	case len(head) > tarMagicOffset && bytes.HasPrefix(head[tarMagicOffset:], tarMagic):
+		return ioutil.NopCloser(br), nil
+	}
+	return nil, errors.New("unknown archive format, expected a tarball compressed with gzip, bzip2, xz, zstd or not at all")
+}
+
+// ExtractFromTar extracts an executable file from a tarball. If sum is not
+// empty, the SHA-256 digest of the tarball is verified before the file is put
+// in place.
+func ExtractFromTar(source string, target string, destination string, sum string) error {
+	return ExtractFilesFromTar(source, []Target{{Source: target, Destination: destination, Mode: 0755}}, sum)
+}
+
+// ExtractFilesFromTar extracts files and directories from a tarball. If sum is
+// not empty, the SHA-256 digest of the tarball is verified before the files are
+// put in place.
+func ExtractFilesFromTar(source string, targets []Target, sum string) error {
+	d, err := openWithProgress(source, label(targets))
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer d.Close()
 
-	var in io.Reader
+	h := sha256.New()
+	body := io.TeeReader(d, h)
 
-	if strings.HasSuffix(source, "gz") {
-		in, err = gzip.NewReader(resp.Body)
-	} else if strings.HasSuffix(source, "bz2") {
-		in = bzip2.NewReader(resp.Body)
-	}
+	in, err := decompress(body)
 	if err != nil {
+		return fmt.Errorf("%s: %s", source, err)
+	}
+	defer in.Close()
+
+	// Extract to temporary files, which are only moved into place if the tarball is verified
+	x := newExtraction(targets)
+	defer x.cleanup()
+	if err = extractTar(in, x); err != nil {
+		return err
+	}
+	if sum != "" {
+		// Read the rest of the tarball to get its full digest
+		if _, err = io.Copy(ioutil.Discard, body); err != nil {
+			return err
+		}
+		if err = verifySHA256(h, sum); err != nil {
+			return err
+		}
+	}
+	if err = x.missing(); err != nil {
 		return err
 	}
+	return x.commit()
+}
 
+// extractTar extracts the entries of a tar stream
+func extractTar(in io.Reader, x *extraction) error {
 	tr := tar.NewReader(in)
 	for {
 		header, err := tr.Next()
@@ -57,53 +135,100 @@ func ExtractFromTar(source string, target string, destination string) error {
 		case header == nil:
 			continue
 		}
-		if header.Name == target {
-			out, err := os.Create(destination)
-			if err != nil {
-				return err
-			}
-			defer out.Close()
-			_, err = io.Copy(out, tr)
-			if err != nil {
-				return err
-			}
+		x.see(header.Name)
+		mode := header.FileInfo().Mode()
+		switch {
+		case mode.IsRegular():
+			err = x.extract(header.Name, mode, tr)
+		case header.Typeflag == tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		}
+		if err != nil {
+			return err
 		}
 	}
 }
 
-// ExtractFromZip extracts a file from a zip-file
-func ExtractFromZip(source string, target string, destination string) error {
-	resp, err := http.Get(source)
+// label returns the label of the progress bar of an archive, which is the
+// name of its first target
+func label(targets []Target) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	return filepath.Base(targets[0].Destination)
+}
+
+// ExtractFromZip extracts an executable file from a zip-file. If sum is not
+// empty, the SHA-256 digest of the zip-file is verified before extracting.
+func ExtractFromZip(source string, target string, destination string, sum string) error {
+	return ExtractFilesFromZip(source, []Target{{Source: target, Destination: destination, Mode: 0755}}, sum)
+}
+
+// ExtractFilesFromZip extracts files and directories from a zip-file. If sum is
+// not empty, the SHA-256 digest of the zip-file is verified before extracting.
+// The zip-file is streamed to a temporary file, as it can only be read from the end.
+func ExtractFilesFromZip(source string, targets []Target, sum string) error {
+	d, err := openWithProgress(source, label(targets))
 	if err != nil {
 		return err
 	}
-	contentZipped, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	readerAt := bytes.NewReader(contentZipped)
-	zr, err := zip.NewReader(readerAt, int64(len(contentZipped)))
+	defer d.Close()
+	tmp, err := ioutil.TempFile("", "vk-*.zip")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(tmp, io.TeeReader(d, h))
+	d.Close()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if sum != "" {
+		if err = verifySHA256(h, sum); err != nil {
+			return err
+		}
+	}
+	zr, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("%s: %s", source, err)
+	}
+	defer zr.Close()
+	x := newExtraction(targets)
+	defer x.cleanup()
 	for _, f := range zr.File {
-		if f.Name == target {
-			rc, err := f.Open()
-			if err != nil {
-				return err
-			}
-			defer rc.Close()
-			outFile, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-			if err != nil {
-				return err
-			}
-
-			_, err = io.Copy(outFile, rc)
-			// Close the file without defer to close before next iteration of loop
-			outFile.Close()
-			if err != nil {
-				return err
-			}
+		if err = extractZipFile(f, x); err != nil {
+			return err
 		}
+	}
+	if err = x.missing(); err != nil {
+		return err
+	}
+	return x.commit()
+}
 
+// extractZipFile extracts an entry of a zip-file
+func extractZipFile(f *zip.File, x *extraction) error {
+	x.see(f.Name)
+	mode := f.Mode()
+	if !mode.IsRegular() && mode&os.ModeSymlink == 0 {
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if mode&os.ModeSymlink != 0 {
+		// The content of a symbolic link is the path it links to
+		linkname, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return err
+		}
+		return x.symlink(f.Name, string(linkname))
 	}
-	return nil
+	return x.extract(f.Name, mode, rc)
 }
@@ -0,0 +1,328 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// testTool is the content of tool/bin/tool in the test archives
+const testTool = "#!/bin/sh\necho tool\n"
+
+// testBzip2 is a tarball of tool/bin/tool compressed with bzip2, which Go can only decompress
+const testBzip2 = "QlpoOTFBWSZTWe9pIu8AAH9bgMqRaAD+gAgAemWeAAgIIAB1DKamg0AAeoDaQSVAaaANANGhpF9pKhAydCEXQdUOsaxAiQK3zEMoup8M2iqcCFQjFX6u4zetJbkfK0YjcsFQwLVygmzSwJIfi7kinChId7SRd4A="
+
+// testFiles are the files of the test archives
+var testFiles = map[string]string{
+	"tool/bin/tool":                testTool,
+	"tool/share/doc/README.md":     "readme",
+	"tool/share/man/man1/tool.1":   "manual",
+	"tool/completion/tool.bash":    "bash",
+	"tool/completion/tool.zsh":     "zsh",
+	"tool/../../../../tmp/escaped": "escaped",
+}
+
+// sortedNames returns the names of files, sorted
+func sortedNames(files map[string]string) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// testMode returns the mode of a file in the test archives, which is executable in bin
+func testMode(name string) os.FileMode {
+	if strings.Contains(name, "/bin/") {
+		return 0755
+	}
+	return 0644
+}
+
+// testTar returns a tarball of the files
+func testTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, name := range sortedNames(files) {
+		h := &tar.Header{Name: name, Mode: int64(testMode(name)), Size: int64(len(files[name])), Format: tar.FormatUSTAR}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[name]))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// testZip returns a zip-file of the files
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range sortedNames(files) {
+		h := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		h.SetMode(testMode(name))
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// compress compresses d with a writer of a compression format
+func compress(t *testing.T, d []byte, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := newWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(d)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// sha256Hex returns the hex encoded SHA-256 digest of d
+func sha256Hex(d []byte) string {
+	h := sha256.Sum256(d)
+	return hex.EncodeToString(h[:])
+}
+
+func TestDecompress(t *testing.T) {
+	tarball := testTar(t, map[string]string{"tool/bin/tool": testTool})
+	bzip2Tarball, _ := base64.StdEncoding.DecodeString(testBzip2)
+	for _, tc := range []struct {
+		format string
+		d      []byte
+	}{
+		{"tar", tarball},
+		{"gzip", compress(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })},
+		{"bzip2", bzip2Tarball},
+		{"xz", compress(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) })},
+		{"zstd", compress(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) })},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			in, err := decompress(bytes.NewReader(tc.d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(in)
+			h, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Name != "tool/bin/tool" || string(d) != testTool {
+				t.Errorf("read %s: %q", h.Name, d)
+			}
+			if err = in.Close(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	for _, d := range [][]byte{nil, []byte("not an archive"), testZip(t, testFiles)} {
+		if _, err := decompress(bytes.NewReader(d)); err == nil {
+			t.Errorf("decompress(%.10q) succeeded", d)
+		}
+	}
+}
+
+func TestTargetMatch(t *testing.T) {
+	for _, tc := range []struct {
+		source      string
+		destination string
+		name        string
+		dest        string
+		ok          bool
+	}{
+		{"tool/bin/tool", "/bin/tool", "tool/bin/tool", "/bin/tool", true},
+		{"tool/bin/tool", "/bin/tool", "./tool/bin/tool", "/bin/tool", true},
+		{"*/bin/tool", "/bin/tool", "tool-1.0.0/bin/tool", "/bin/tool", true},
+		{"tool/bin/tool", "/bin/tool", "tool/bin/tool2", "", false},
+		{"tool/bin/tool", "/bin/", "tool/bin/tool", "/bin/tool", true},
+		// Directories are extracted with their content
+		{"tool/share", "/prefix/share", "tool/share/man/man1/tool.1", "/prefix/share/man/man1/tool.1", true},
+		{"tool/share", "/prefix/", "tool/share/doc/README.md", "/prefix/share/doc/README.md", true},
+		{"tool/completion/*.bash", "/completions/", "tool/completion/tool.bash", "/completions/tool.bash", true},
+		{"tool/completion/*.bash", "/completions/", "tool/completion/tool.zsh", "", false},
+		// Paths can't point outside of the archive
+		{"tmp/escaped", "/prefix/escaped", "tool/../../../../tmp/escaped", "/prefix/escaped", true},
+		{"tool/bin", "/prefix/bin", "tool/bin/../../../etc/passwd", "", false},
+	} {
+		dest, ok := Target{Source: tc.source, Destination: tc.destination}.match(tc.name)
+		if dest != filepath.FromSlash(tc.dest) || ok != tc.ok {
+			t.Errorf("Target{%s, %s}.match(%s) = %s, %t, expected %s, %t", tc.source, tc.destination, tc.name, dest, ok, tc.dest, tc.ok)
+		}
+	}
+}
+
+// serveArchive serves an archive for a test, and returns its URL
+func serveArchive(t *testing.T, d []byte) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(d)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+// assertFiles checks the content of files in dir. Files with empty content
+// must not exist.
+func assertFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		d, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if content == "" {
+			if err == nil {
+				t.Errorf("%s is extracted", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s is not extracted: %s", name, err)
+		} else if string(d) != content {
+			t.Errorf("%s is %q, expected %q", name, d, content)
+		}
+	}
+}
+
+// testArchive is a test archive, and the function extracting it
+type testArchive struct {
+	format  string
+	d       []byte
+	extract func(string, []Target, string) error
+}
+
+// testArchives returns the test files as a tarball and as a zip-file
+func testArchives(t *testing.T) []testArchive {
+	tarball := compress(t, testTar(t, testFiles), func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+	return []testArchive{
+		{"tar", tarball, ExtractFilesFromTar},
+		{"zip", testZip(t, testFiles), ExtractFilesFromZip},
+	}
+}
+
+func TestExtractFiles(t *testing.T) {
+	for _, tc := range testArchives(t) {
+		t.Run(tc.format, func(t *testing.T) {
+			dir := t.TempDir()
+			targets := []Target{
+				{Source: "*/bin/tool", Destination: filepath.Join(dir, "bin", "tool")},
+				{Source: "tool/share", Destination: filepath.Join(dir, "share")},
+				{Source: "tool/completion/*.bash", Destination: filepath.Join(dir, "completion") + "/"},
+				{Source: "tmp/escaped", Destination: filepath.Join(dir, "escaped")},
+			}
+			if err := tc.extract(serveArchive(t, tc.d), targets, sha256Hex(tc.d)); err != nil {
+				t.Fatal(err)
+			}
+			assertFiles(t, dir, map[string]string{
+				"bin/tool":                testTool,
+				"share/doc/README.md":     "readme",
+				"share/man/man1/tool.1":   "manual",
+				"completion/tool.bash":    "bash",
+				"completion/tool.zsh":     "",
+				"escaped":                 "escaped",
+				"../../../../tmp/escaped": "",
+			})
+			fi, err := os.Stat(filepath.Join(dir, "bin", "tool"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != 0755 {
+				t.Errorf("mode of bin/tool is %s, expected 0755", fi.Mode())
+			}
+		})
+		t.Run(tc.format+" with missing target", func(t *testing.T) {
+			dir := t.TempDir()
+			targets := []Target{
+				{Source: "tool/bin/tool", Destination: filepath.Join(dir, "tool")},
+				{Source: "tool/bin/missing", Destination: filepath.Join(dir, "missing")},
+			}
+			err := tc.extract(serveArchive(t, tc.d), targets, "")
+			if !errors.Is(err, ErrTargetNotFound) {
+				t.Fatalf("extracting a missing target returned %v, expected %v", err, ErrTargetNotFound)
+			}
+			// The entries of the archive are listed, to help fixing the definition
+			if !strings.Contains(err.Error(), "tool/share/doc/README.md") {
+				t.Errorf("entries are not listed: %s", err)
+			}
+			// Nothing is put in place if a target is missing
+			assertFiles(t, dir, map[string]string{"tool": ""})
+		})
+		t.Run(tc.format+" with checksum mismatch", func(t *testing.T) {
+			dir := t.TempDir()
+			targets := []Target{{Source: "tool/bin/tool", Destination: filepath.Join(dir, "tool")}}
+			if err := tc.extract(serveArchive(t, tc.d), targets, sha256Hex([]byte("other"))); err == nil {
+				t.Error("extracting with a mismatching checksum succeeded")
+			}
+			// Nothing is put in place if the archive can't be verified
+			assertFiles(t, dir, map[string]string{"tool": ""})
+		})
+	}
+}
+
+func TestExtractFilesHTTPError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/tool.tar.gz" {
+			http.Redirect(w, r, "/v1.0.0/tool.tar.gz", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+	for _, tc := range testArchives(t) {
+		dir := t.TempDir()
+		targets := []Target{{Source: "tool/bin/tool", Destination: filepath.Join(dir, "tool")}}
+		err := tc.extract(s.URL+"/latest/tool.tar.gz", targets, "")
+		// The status is reported with the URL that was redirected to
+		expected := s.URL + "/latest/tool.tar.gz: HTTP status 404 Not Found from " + s.URL + "/v1.0.0/tool.tar.gz"
+		if err == nil || err.Error() != expected {
+			t.Errorf("extracting %s returned %v, expected %s", tc.format, err, expected)
+		}
+		assertFiles(t, dir, map[string]string{"tool": ""})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// tempFile creates a temporary file in the directory of destination, so it
+// can be renamed over destination once it is complete
+func tempFile(destination string) (*os.File, error) {
+	dir := filepath.Dir(destination)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dir, "."+filepath.Base(destination)+".*.download")
+}
+
+// closeFile flushes a file to disk, sets its mode and closes it
+func closeFile(f *os.File, mode os.FileMode) error {
+	err := f.Sync()
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Errors returned when verifying downloads
+var (
+	ErrChecksumMismatch = errors.New("SHA-256 mismatch")
+	ErrInvalidSignature = errors.New("invalid GPG signature")
+)
+
+// SHA256 returns the hex encoded SHA-256 digest of a file
+func SHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FetchChecksum downloads a checksum file like checksums.txt or *_SHA256SUMS
+// and returns the SHA-256 digest listed for name. Checksum files containing
+// only a single digest are also supported.
+func FetchChecksum(source string, name string) (string, error) {
+	d, err := Fetch(source)
+	if err != nil {
+		return "", err
+	}
+	return findChecksum(bytes.NewReader(d), name, source)
+}
+
+// findChecksum finds the digest of name in a checksum file in sha256sum format
+func findChecksum(r io.Reader, name string, source string) (string, error) {
+	var lines [][]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(lines) == 1 && len(lines[0]) == 1 {
+		return strings.ToLower(lines[0][0]), nil
+	}
+	for _, fields := range lines {
+		if len(fields) < 2 {
+			continue
+		}
+		// sha256sum marks files checksummed in binary mode with a leading *
+		f := strings.TrimPrefix(fields[len(fields)-1], "*")
+		if f == name || path.Base(f) == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("can't find checksum of %s in %s", name, source)
+}
+
+// verifySHA256 compares the digest computed by h with the expected hex encoded digest
+func verifySHA256(h hash.Hash, sum string) error {
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, sum) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, sum, actual)
+	}
+	return nil
+}
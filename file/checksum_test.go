@@ -0,0 +1,58 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	sumA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	sumB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestFindChecksum(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		sums     string
+		file     string
+		checksum string
+	}{
+		{"sha256sum", sumA + "  tool_linux_amd64.tar.gz\n" + sumB + "  tool_darwin_amd64.tar.gz\n", "tool_darwin_amd64.tar.gz", sumB},
+		{"binary mode", sumA + " *tool_linux_amd64.tar.gz\n", "tool_linux_amd64.tar.gz", sumA},
+		{"paths", sumA + "  ./dist/tool_linux_amd64.tar.gz\n", "tool_linux_amd64.tar.gz", sumA},
+		{"upper case", strings.ToUpper(sumA) + "  tool.zip\n", "tool.zip", sumA},
+		{"CRLF and blank lines", "\r\n" + sumB + "  other.zip\r\n\r\n" + sumA + "  tool.zip\r\n", "tool.zip", sumA},
+		{"single digest", sumA + "\n", "tool.zip", sumA},
+		{"single digest without newline", sumA, "tool.zip", sumA},
+		{"prefix of another file", sumA + "  tool.zip.sig\n" + sumB + "  tool.zip\n", "tool.zip", sumB},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			checksum, err := findChecksum(strings.NewReader(tc.sums), tc.file, "SHA256SUMS")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checksum != tc.checksum {
+				t.Errorf("findChecksum() = %s, expected %s", checksum, tc.checksum)
+			}
+		})
+	}
+	for _, sums := range []string{"", sumA + "  other.zip\n", sumA + "\n" + sumB + "\n"} {
+		if checksum, err := findChecksum(strings.NewReader(sums), "tool.zip", "SHA256SUMS"); err == nil {
+			t.Errorf("findChecksum(%q) = %s", sums, checksum)
+		}
+	}
+}
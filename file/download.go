@@ -0,0 +1,56 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Download downloads an executable file to destination. If sum is not empty,
+// the SHA-256 digest of the file is verified. The file is downloaded to a
+// temporary file, which is flushed to disk and made executable before it is
+// renamed to destination, so destination is left untouched if it fails.
+func Download(source string, destination string, sum string) error {
+	d, err := openWithProgress(source, filepath.Base(destination))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	tmp, err := tempFile(destination)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), d)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if sum != "" {
+		if err = verifySHA256(h, sum); err != nil {
+			tmp.Close()
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	if err = closeFile(tmp, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), destination)
+}
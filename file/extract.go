@@ -0,0 +1,202 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTargetNotFound is returned if a target does not match any path in an archive
+var ErrTargetNotFound = errors.New("not found in archive")
+
+// maxListedEntries is the number of entries listed when a target is not found
+const maxListedEntries = 20
+
+// Target is a file or directory to extract from an archive
+type Target struct {
+	Source      string      // Glob matching paths in the archive. A matching directory is extracted with all its content.
+	Destination string      // Path the match is extracted to. A match is put into a Destination ending with a path separator under its base name.
+	Mode        os.FileMode `json:"-"` // Optional, mode of the extracted files. Defaults to the mode in the archive.
+}
+
+// cleanPath returns an archive path relative to the root of the archive. Paths
+// can't point outside of the archive, as .. is dropped at the root.
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// match returns the destination of an archive path, and whether the target matches it
+func (t Target) match(name string) (string, bool) {
+	pattern := cleanPath(t.Source)
+	name = cleanPath(name)
+	// Match the path itself, and then each of its parent directories
+	for dir, rel := name, ""; dir != "." && dir != ""; dir, rel = path.Dir(dir), path.Join(path.Base(dir), rel) {
+		if ok, _ := path.Match(pattern, dir); !ok {
+			continue
+		}
+		dest := t.Destination
+		if strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator)) {
+			dest = filepath.Join(dest, path.Base(dir))
+		}
+		return filepath.Join(dest, filepath.FromSlash(rel)), true
+	}
+	return "", false
+}
+
+// extraction extracts the entries of an archive matched by targets to temporary
+// files, which are only moved into place by commit
+type extraction struct {
+	targets []Target
+	found   []bool            // Whether each target matched an entry
+	entries []string          // Paths of the entries in the archive
+	files   map[string]string // Destinations by temporary file
+}
+
+func newExtraction(targets []Target) *extraction {
+	return &extraction{
+		targets: targets,
+		found:   make([]bool, len(targets)),
+		files:   make(map[string]string),
+	}
+}
+
+// see records an entry of the archive, and the targets matching it
+func (x *extraction) see(name string) {
+	x.entries = append(x.entries, name)
+	for i, t := range x.targets {
+		if _, ok := t.match(name); ok {
+			x.found[i] = true
+		}
+	}
+}
+
+// missing returns an error if a target has not matched any entry of the archive
+func (x *extraction) missing() error {
+	for i, t := range x.targets {
+		if x.found[i] {
+			continue
+		}
+		entries := x.entries
+		more := ""
+		if len(entries) > maxListedEntries {
+			more = fmt.Sprintf(" and %d more", len(entries)-maxListedEntries)
+			entries = entries[:maxListedEntries]
+		}
+		if len(entries) == 0 {
+			return fmt.Errorf("target %s %w, which is empty", t.Source, ErrTargetNotFound)
+		}
+		return fmt.Errorf("target %s %w; entries were: %s%s", t.Source, ErrTargetNotFound, strings.Join(entries, ", "), more)
+	}
+	return nil
+}
+
+// matches returns the targets matching an archive entry, and the paths it is extracted to
+func (x *extraction) matches(name string) ([]Target, []string) {
+	var targets []Target
+	var dests []string
+	for _, t := range x.targets {
+		if dest, ok := t.match(name); ok {
+			targets = append(targets, t)
+			dests = append(dests, dest)
+		}
+	}
+	return targets, dests
+}
+
+// extract copies the content of an archive entry to temporary files for its
+// destinations, which are flushed to disk before they can be committed
+func (x *extraction) extract(name string, mode os.FileMode, r io.Reader) error {
+	targets, dests := x.matches(name)
+	if len(dests) == 0 {
+		return nil
+	}
+	var outs []*os.File
+	var w []io.Writer
+	for _, dest := range dests {
+		out, err := tempFile(dest)
+		if err != nil {
+			for _, o := range outs {
+				o.Close()
+			}
+			return err
+		}
+		x.files[out.Name()] = dest
+		outs = append(outs, out)
+		w = append(w, out)
+	}
+	_, err := io.Copy(io.MultiWriter(w...), r)
+	for i, out := range outs {
+		m := targets[i].Mode
+		if m == 0 {
+			m = mode.Perm()
+		}
+		if m == 0 {
+			m = 0644
+		}
+		if cerr := closeFile(out, m); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// symlink creates a symbolic link of an archive entry at its destinations.
+// Links must point to a path within the archive.
+func (x *extraction) symlink(name string, linkname string) error {
+	_, dests := x.matches(name)
+	if len(dests) == 0 {
+		return nil
+	}
+	if path.IsAbs(linkname) || strings.HasPrefix(path.Join(path.Dir(cleanPath(name)), linkname), "..") {
+		return fmt.Errorf("%s links to %s, outside of the archive", name, linkname)
+	}
+	for _, dest := range dests {
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		tmp := dest + ".download"
+		os.Remove(tmp)
+		if err := os.Symlink(filepath.FromSlash(linkname), tmp); err != nil {
+			return err
+		}
+		x.files[tmp] = dest
+	}
+	return nil
+}
+
+// commit moves the extracted files into place. Files are renamed over the
+// files they replace, which are left untouched until then.
+func (x *extraction) commit() error {
+	for tmp, dest := range x.files {
+		if err := os.Rename(tmp, dest); err != nil {
+			return err
+		}
+		delete(x.files, tmp)
+	}
+	return nil
+}
+
+// cleanup removes the temporary files which were not committed
+func (x *extraction) cleanup() {
+	for tmp := range x.files {
+		os.Remove(tmp)
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cellpointmobile/vk/progress"
+	"github.com/gregjones/httpcache"
+)
+
+// Settings of downloads
+var (
+	Retries        = 3                // Number of times a failed download is retried
+	RetryDelay     = time.Second      // Delay before the first retry, which doubles with each retry
+	ConnectTimeout = 30 * time.Second // Timeout of connecting to a server
+	ReadTimeout    = 60 * time.Second // Timeout of waiting for data from a server
+	Context        = context.Background()
+)
+
+// maxRetryDelay is the longest delay between retries
+const maxRetryDelay = 30 * time.Second
+
+// ErrTimeout is returned if a server sends no data within ReadTimeout
+var ErrTimeout = errors.New("timed out waiting for data")
+
+var (
+	headersMu   sync.RWMutex
+	headers     = make(map[string]http.Header)
+	authorizers = make(map[string]func(*http.Request) error)
+	caches      = make(map[string]httpcache.Cache)
+
+	clientOnce sync.Once
+	client     *http.Client
+)
+
+// SetHeader sets a header sent with every request of a URL starting with
+// prefix, ex: an API token for https://gitlab.example.com/
+func SetHeader(prefix string, key string, value string) {
+	headersMu.Lock()
+	defer headersMu.Unlock()
+	if headers[prefix] == nil {
+		headers[prefix] = make(http.Header)
+	}
+	headers[prefix].Set(key, value)
+}
+
+// SetAuthorizer sets a function authorizing every request of a URL starting
+// with prefix, ex: by signing it. It is called right before each request is
+// sent, including retries and resumed downloads.
+func SetAuthorizer(prefix string, authorize func(r *http.Request) error) {
+	headersMu.Lock()
+	defer headersMu.Unlock()
+	authorizers[prefix] = authorize
+}
+
+// SetCache sets a cache of the responses of URLs starting with prefix, ex: for
+// an API listing releases. Cached responses are revalidated as their headers
+// require.
+func SetCache(prefix string, cache httpcache.Cache) {
+	headersMu.Lock()
+	defer headersMu.Unlock()
+	caches[prefix] = cache
+}
+
+// matchingPrefixes returns the prefixes of u, shortest first
+func matchingPrefixes(u string, prefixes []string) []string {
+	var matching []string
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(u, prefix) {
+			matching = append(matching, prefix)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return len(matching[i]) < len(matching[j])
+	})
+	return matching
+}
+
+// authorize adds the headers set for prefixes of the URL of the request, and
+// runs the authorizer of its longest prefix. Headers of longer prefixes
+// override those of shorter prefixes.
+func authorize(r *http.Request) error {
+	headersMu.RLock()
+	u := r.URL.String()
+	var prefixes []string
+	for prefix := range headers {
+		prefixes = append(prefixes, prefix)
+	}
+	for _, prefix := range matchingPrefixes(u, prefixes) {
+		for k, v := range headers[prefix] {
+			r.Header[k] = v
+		}
+	}
+	prefixes = prefixes[:0]
+	for prefix := range authorizers {
+		prefixes = append(prefixes, prefix)
+	}
+	var authorizer func(*http.Request) error
+	if matching := matchingPrefixes(u, prefixes); len(matching) > 0 {
+		authorizer = authorizers[matching[len(matching)-1]]
+	}
+	headersMu.RUnlock()
+	if authorizer == nil {
+		return nil
+	}
+	return authorizer(r)
+}
+
+// getClient returns the HTTP client of requests. Only connecting has a
+// timeout, as reads are timed out by send.
+func getClient() *http.Client {
+	clientOnce.Do(func() {
+		client = &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout:   ConnectTimeout,
+					KeepAlive: 30 * time.Second,
+				}).DialContext,
+				TLSHandshakeTimeout: ConnectTimeout,
+			},
+		}
+	})
+	return client
+}
+
+// getCachingClient returns the HTTP client of requests of u, which uses the
+// cache of the longest prefix of u if any
+func getCachingClient(u string) *http.Client {
+	headersMu.RLock()
+	var prefixes []string
+	for prefix := range caches {
+		prefixes = append(prefixes, prefix)
+	}
+	var cache httpcache.Cache
+	if matching := matchingPrefixes(u, prefixes); len(matching) > 0 {
+		cache = caches[matching[len(matching)-1]]
+	}
+	headersMu.RUnlock()
+	if cache == nil {
+		return getClient()
+	}
+	return &http.Client{Transport: &httpcache.Transport{Transport: getClient().Transport, Cache: cache}}
+}
+
+// statusError is an HTTP response with an unexpected status
+type statusError struct {
+	url    string
+	final  string // URL of the response, after redirects
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	if e.final != "" && e.final != e.url {
+		return fmt.Sprintf("%s: HTTP status %s from %s", e.url, e.status, e.final)
+	}
+	return fmt.Sprintf("%s: HTTP status %s", e.url, e.status)
+}
+
+// temporary returns true if the request may succeed when retried
+func (e *statusError) temporary() bool {
+	return isTemporary(e.code)
+}
+
+// isTemporary returns true if a request with the HTTP status code may succeed when retried
+func isTemporary(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// newStatusError returns an error for a response with an unexpected status
+func newStatusError(source string, resp *http.Response) error {
+	return &statusError{url: source, final: resp.Request.URL.String(), code: resp.StatusCode, status: resp.Status}
+}
+
+// send sends a GET request of source with the headers set for its URL. It is
+// cancelled if no data is received within ReadTimeout, or when Context is done.
+func send(source string, header http.Header) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(Context)
+	timer := time.AfterFunc(ReadTimeout, func() {
+		cancel(ErrTimeout)
+	})
+	stop := func() {
+		timer.Stop()
+		cancel(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err = authorize(req); err != nil {
+		stop()
+		return nil, err
+	}
+	resp, err := getCachingClient(source).Do(req)
+	if err != nil {
+		err = timeoutError(ctx, source, err)
+		stop()
+		return nil, err
+	}
+	resp.Body = &timedReader{ReadCloser: resp.Body, ctx: ctx, source: source, timer: timer, stop: stop}
+	return resp, nil
+}
+
+// timeoutError returns ErrTimeout instead of the error of a request cancelled
+// because it timed out
+func timeoutError(ctx context.Context, source string, err error) error {
+	if errors.Is(context.Cause(ctx), ErrTimeout) {
+		return fmt.Errorf("%s: %w, no data received for %s", source, ErrTimeout, ReadTimeout)
+	}
+	return err
+}
+
+// Get sends a GET request of source with the headers set for its URL, and
+// returns the response, whatever its status. Requests failing with network
+// errors or temporary statuses, like 503, are retried. Reading the body times
+// out if no data is received within ReadTimeout.
+func Get(source string, header http.Header) (*http.Response, error) {
+	// The download only counts the failures, for the delay between retries
+	d := &download{source: source, size: -1}
+	for {
+		resp, err := send(source, header)
+		if err == nil && !isTemporary(resp.StatusCode) {
+			return resp, nil
+		}
+		if err == nil {
+			err = newStatusError(source, resp)
+			if d.failures >= Retries || Context.Err() != nil {
+				// Out of retries, the caller handles the status
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+		if !d.retry(err) {
+			return nil, err
+		}
+	}
+}
+
+// Fetch downloads a small file, like a checksum file or a list of versions,
+// into memory. Responses with other statuses than 200 OK are returned as errors.
+func Fetch(source string) ([]byte, error) {
+	d, err := open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	return ioutil.ReadAll(d)
+}
+
+// download is a resumable download of a URL. When a download fails, it is
+// resumed from where it stopped with a Range request, or from the start if the
+// server does not support ranges.
+type download struct {
+	source   string
+	offset   int64 // Number of bytes read
+	size     int64 // Size of the download, or -1 if unknown
+	failures int   // Number of failures since data was last read
+	body     io.ReadCloser
+	bar      *progress.Bar
+}
+
+// open starts a download of source. It returns once the response has been
+// received, so errors like a missing file are returned right away.
+func open(source string) (*download, error) {
+	d := &download{source: source, size: -1}
+	if err := d.connect(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// openWithProgress starts a download of source, which shows a progress bar
+// with label while it is read
+func openWithProgress(source string, label string) (*download, error) {
+	d, err := open(source)
+	if err != nil {
+		return nil, err
+	}
+	d.bar = progress.New(label, d.size)
+	return d, nil
+}
+
+// connect requests the rest of the download, retrying until it succeeds
+func (d *download) connect() error {
+	for {
+		err := d.request()
+		if err == nil {
+			return nil
+		}
+		if !d.retry(err) {
+			return err
+		}
+	}
+}
+
+// request sends a request for the rest of the download
+func (d *download) request() error {
+	header := make(http.Header)
+	if d.offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+	}
+	resp, err := send(d.source, header)
+	if err != nil {
+		return err
+	}
+	d.body = resp.Body
+	skip := int64(0)
+	switch {
+	case d.offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, err := rangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start > d.offset {
+			d.disconnect()
+			return fmt.Errorf("%s: can't resume download at byte %d from Content-Range '%s'",
+				d.source, d.offset, resp.Header.Get("Content-Range"))
+		}
+		// Skip what has been read, if the range starts before it
+		skip = d.offset - start
+	case resp.StatusCode == http.StatusOK:
+		if d.offset == 0 {
+			d.size = resp.ContentLength
+		}
+		// The server does not support ranges, so skip what has been read
+		skip = d.offset
+	default:
+		d.disconnect()
+		return newStatusError(d.source, resp)
+	}
+	if skip > 0 {
+		if _, err = io.CopyN(ioutil.Discard, d.body, skip); err != nil {
+			d.disconnect()
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeStart returns the first byte of a Content-Range header, ex: 100 for "bytes 100-199/200"
+func rangeStart(contentRange string) (int64, error) {
+	var start, end int64
+	var size string
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &size); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
+
+// retry waits before retrying a failed request. Returns false if the request
+// should not be retried.
+func (d *download) retry(err error) bool {
+	if Context.Err() != nil || d.failures >= Retries {
+		return false
+	}
+	if se, ok := err.(*statusError); ok && !se.temporary() {
+		return false
+	}
+	// Exponential backoff with jitter, so clients don't retry at the same time
+	delay := RetryDelay << uint(d.failures)
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	d.failures++
+	select {
+	case <-time.After(delay):
+		return true
+	case <-Context.Done():
+		return false
+	}
+}
+
+// timedReader is the body of a response, which restarts the timer of
+// ReadTimeout on every read. Closing it stops the timer and the request.
+type timedReader struct {
+	io.ReadCloser
+	ctx    context.Context
+	source string
+	timer  *time.Timer
+	stop   func()
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	r.timer.Reset(ReadTimeout)
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = timeoutError(r.ctx, r.source, err)
+	}
+	return n, err
+}
+
+func (r *timedReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.stop()
+	return err
+}
+
+// Read reads the download, and resumes it if it fails
+func (d *download) Read(p []byte) (int, error) {
+	for {
+		if d.body == nil {
+			if err := d.connect(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := d.body.Read(p)
+		d.offset += int64(n)
+		d.bar.Add(n)
+		if n > 0 {
+			d.failures = 0
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		d.disconnect()
+		if !d.retry(err) {
+			if Context.Err() != nil {
+				err = Context.Err()
+			}
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close stops the download and removes its progress bar
+func (d *download) Close() error {
+	d.bar.Done()
+	d.bar = nil
+	return d.disconnect()
+}
+
+// disconnect closes the connection of the download, which is resumed by the next Read
+func (d *download) disconnect() error {
+	if d.body == nil {
+		return nil
+	}
+	err := d.body.Close()
+	d.body = nil
+	return err
+}
@@ -0,0 +1,287 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBody is downloaded by the tests
+var testBody = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+// fastRetries makes failed requests retry without delay during a test
+func fastRetries(t *testing.T, retries int) {
+	t.Helper()
+	r, d, rt := Retries, RetryDelay, ReadTimeout
+	t.Cleanup(func() {
+		Retries, RetryDelay, ReadTimeout = r, d, rt
+	})
+	Retries, RetryDelay = retries, time.Millisecond
+}
+
+// serveTruncated sends the headers of the full body, but only n bytes of it
+func serveTruncated(t *testing.T, w http.ResponseWriter, status int, header string, body []byte, n int) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\nContent-Length: %d\r\n%s\r\n", status, http.StatusText(status), len(body), header)
+	buf.Write(body[:n])
+	buf.Flush()
+}
+
+// downloadFile downloads source to a temporary file and returns its content
+func downloadFile(t *testing.T, source string, sum string) ([]byte, error) {
+	t.Helper()
+	dest := filepath.Join(t.TempDir(), "tool")
+	if err := Download(source, dest, sum); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(dest)
+}
+
+func TestDownloadResume(t *testing.T) {
+	fastRetries(t, 3)
+	var ranges []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		var start int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err != nil {
+			// Fail halfway through the first request
+			serveTruncated(t, w, http.StatusOK, "", testBody, len(testBody)/2)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(testBody)-1, len(testBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testBody[start:])
+	}))
+	defer s.Close()
+	d, err := downloadFile(t, s.URL, sha256Hex(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, testBody) {
+		t.Error("resumed download differs from the original")
+	}
+	expected := fmt.Sprintf("bytes=%d-", len(testBody)/2)
+	if len(ranges) != 2 || ranges[1] != expected {
+		t.Errorf("requested ranges %q, expected a second request of %s", ranges, expected)
+	}
+}
+
+func TestDownloadResumeWithoutRanges(t *testing.T) {
+	fastRetries(t, 3)
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server ignores ranges, and sends the full body every time
+		if atomic.AddInt32(&requests, 1) == 1 {
+			serveTruncated(t, w, http.StatusOK, "", testBody, 1000)
+			return
+		}
+		w.Write(testBody)
+	}))
+	defer s.Close()
+	d, err := downloadFile(t, s.URL, sha256Hex(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, testBody) {
+		t.Error("restarted download differs from the original")
+	}
+}
+
+func TestDownloadResumeAtWrongOffset(t *testing.T) {
+	fastRetries(t, 3)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			serveTruncated(t, w, http.StatusOK, "", testBody, 1000)
+			return
+		}
+		// A range starting after the requested offset would leave a gap
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 2000-%d/%d", len(testBody)-1, len(testBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testBody[2000:])
+	}))
+	defer s.Close()
+	if _, err := downloadFile(t, s.URL, ""); err == nil || !strings.Contains(err.Error(), "can't resume") {
+		t.Errorf("download returned %v, expected an error resuming it", err)
+	}
+}
+
+func TestDownloadResumeAtEarlierOffset(t *testing.T) {
+	fastRetries(t, 3)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			serveTruncated(t, w, http.StatusOK, "", testBody, 1000)
+			return
+		}
+		// A range starting before the requested offset overlaps what has been read
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 500-%d/%d", len(testBody)-1, len(testBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testBody[500:])
+	}))
+	defer s.Close()
+	d, err := downloadFile(t, s.URL, sha256Hex(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, testBody) {
+		t.Error("resumed download differs from the original")
+	}
+}
+
+func TestDownloadRetry(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		failures int32
+		retries  int
+		requests int32
+		ok       bool
+	}{
+		{http.StatusServiceUnavailable, 2, 3, 3, true},
+		{http.StatusTooManyRequests, 1, 3, 2, true},
+		{http.StatusBadGateway, 5, 2, 3, false},
+		{http.StatusNotFound, 1, 3, 1, false},
+		{http.StatusForbidden, 1, 3, 1, false},
+	} {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			fastRetries(t, tc.retries)
+			var requests int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.Write(testBody)
+			}))
+			defer s.Close()
+			_, err := downloadFile(t, s.URL, "")
+			if (err == nil) != tc.ok {
+				t.Errorf("download returned %v", err)
+			}
+			if requests != tc.requests {
+				t.Errorf("%d requests sent, expected %d", requests, tc.requests)
+			}
+		})
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testBody)
+	}))
+	defer s.Close()
+	if _, err := downloadFile(t, s.URL, sha256Hex([]byte("other"))); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("download returned %v, expected %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	fastRetries(t, 1)
+	ReadTimeout = 50 * time.Millisecond
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Length", fmt.Sprint(len(testBody)))
+		w.Write(testBody[:1000])
+		w.(http.Flusher).Flush()
+		// Stall until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer s.Close()
+	_, err := downloadFile(t, s.URL, "")
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("download returned %v, expected %v", err, ErrTimeout)
+	}
+	if requests != 2 {
+		t.Errorf("%d requests sent, expected a retry after the timeout", requests)
+	}
+}
+
+func TestGet(t *testing.T) {
+	fastRetries(t, 3)
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Accept", r.Header.Get("Accept"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer s.Close()
+	resp, err := Get(s.URL, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// Only the temporary status is retried, others are returned to the caller
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Get returned status %d, expected %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp.Header.Get("X-Accept") != "application/json" {
+		t.Errorf("request header Accept is %s", resp.Header.Get("X-Accept"))
+	}
+	if requests != 2 {
+		t.Errorf("%d requests sent, expected 2", requests)
+	}
+}
+
+func TestSetAuthorizer(t *testing.T) {
+	fastRetries(t, 3)
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		// Each request, including the resumed one, is authorized with its own range
+		if r.Header.Get("Authorization") != "signed:"+r.Header.Get("Range") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if n == 1 {
+			serveTruncated(t, w, http.StatusOK, "", testBody, 1000)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 1000-%d/%d", len(testBody)-1, len(testBody)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testBody[1000:])
+	}))
+	defer s.Close()
+	SetAuthorizer(s.URL+"/", func(r *http.Request) error {
+		r.Header.Set("Authorization", "signed:"+r.Header.Get("Range"))
+		return nil
+	})
+	d, err := downloadFile(t, s.URL+"/tool", sha256Hex(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(d, testBody) {
+		t.Error("resumed download differs from the original")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Now returns the time signatures are verified at. Signatures made by expired
+// or revoked keys are invalid.
+var Now = time.Now
+
+// FetchSignedChecksum downloads a checksum file and its detached GPG signature,
+// verifies the signature against the armored keyring and returns the SHA-256
+// digest listed for name.
+func FetchSignedChecksum(source string, signature string, keyring io.Reader, name string) (string, error) {
+	keys, err := openpgp.ReadArmoredKeyRing(keyring)
+	if err != nil {
+		return "", fmt.Errorf("%w, can't read GPG keyring: %s", ErrInvalidSignature, err)
+	}
+	d, err := Fetch(source)
+	if err != nil {
+		return "", err
+	}
+	sig, err := Fetch(signature)
+	if err != nil {
+		return "", err
+	}
+	config := &packet.Config{Time: Now}
+	if _, err = openpgp.CheckDetachedSignature(keys, bytes.NewReader(d), bytes.NewReader(sig), config); err != nil {
+		return "", fmt.Errorf("%w of %s: %s", ErrInvalidSignature, source, err)
+	}
+	return findChecksum(bytes.NewReader(d), name, source)
+}
@@ -0,0 +1,106 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// newSigner generates a GPG key, and returns it with its armored public key
+func newSigner(t *testing.T, name string) (*openpgp.Entity, string) {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return e, b.String()
+}
+
+// sign returns the detached signature of d
+func sign(t *testing.T, e *openpgp.Entity, d string) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := openpgp.DetachSign(&b, e, strings.NewReader(d), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestFetchSignedChecksum(t *testing.T) {
+	signer, keyring := newSigner(t, "release")
+	other, _ := newSigner(t, "other")
+	sums := sumA + "  tool_1.0.0_linux_amd64.zip\n" + sumB + "  tool_1.0.0_darwin_amd64.zip\n"
+	files := map[string]string{
+		"/SHA256SUMS":           sums,
+		"/SHA256SUMS.sig":       sign(t, signer, sums),
+		"/SHA256SUMS.other.sig": sign(t, other, sums),
+		"/tampered/SHA256SUMS":  strings.Replace(sums, sumB, sumA, 1),
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(d))
+	}))
+	defer s.Close()
+
+	checksum, err := FetchSignedChecksum(s.URL+"/SHA256SUMS", s.URL+"/SHA256SUMS.sig", strings.NewReader(keyring), "tool_1.0.0_darwin_amd64.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != sumB {
+		t.Errorf("FetchSignedChecksum() = %s, expected %s", checksum, sumB)
+	}
+	for _, tc := range []struct {
+		name      string
+		source    string
+		signature string
+		keyring   string
+	}{
+		{"signed by other key", "/SHA256SUMS", "/SHA256SUMS.other.sig", keyring},
+		{"tampered checksums", "/tampered/SHA256SUMS", "/SHA256SUMS.sig", keyring},
+		{"invalid keyring", "/SHA256SUMS", "/SHA256SUMS.sig", "not a keyring"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FetchSignedChecksum(s.URL+tc.source, s.URL+tc.signature, strings.NewReader(tc.keyring), "tool_1.0.0_darwin_amd64.zip")
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("FetchSignedChecksum() returned %v, expected %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+	if _, err = FetchSignedChecksum(s.URL+"/SHA256SUMS", s.URL+"/missing.sig", strings.NewReader(keyring), "tool_1.0.0_darwin_amd64.zip"); err == nil {
+		t.Error("FetchSignedChecksum() with a missing signature succeeded")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testprogram provides a fake program for tests, which installs shell
+// scripts printing their version.
+package testprogram
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cellpointmobile/vk/program"
+)
+
+// Program is a fake program named fake. Its versions are downloaded from
+// https://example.com/fake/<version>.
+type Program struct {
+	program.Command
+}
+
+// New returns a fake program with a bindir in a temporary HOME directory
+func New(t *testing.T) *Program {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	bindir := filepath.Join(home, "bin")
+	if err := os.MkdirAll(bindir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return At(bindir)
+}
+
+// At returns a fake program in the given bindir
+func At(bindir string) *Program {
+	return &Program{program.Command{
+		Path:          bindir,
+		Cmd:           "fake",
+		VersionArg:    "--version",
+		VersionRegexp: `fake version (\S+)`,
+	}}
+}
+
+// GetSource returns the source of the fake program
+func (p *Program) GetSource() string { return "fake" }
+
+// GetLatestVersion returns no version
+func (p *Program) GetLatestVersion() (string, string, error) { return "", "", nil }
+
+// ListVersions returns no versions
+func (p *Program) ListVersions() ([]string, error) { return nil, nil }
+
+// DownloadLatestVersion downloads no version
+func (p *Program) DownloadLatestVersion() (string, error) { return "", nil }
+
+// GetVersion returns the download URL of a version
+func (p *Program) GetVersion(version string) (string, string, error) {
+	return version, "https://example.com/fake/" + version, nil
+}
+
+// DownloadVersion installs a script printing the version
+func (p *Program) DownloadVersion(version string) (string, error) {
+	script := fmt.Sprintf("#!/bin/sh\necho fake version %s\n", version)
+	return version, ioutil.WriteFile(p.GetFullPath(), []byte(script), 0755)
+}
@@ -0,0 +1,64 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// LockedTool records exactly what was installed for a tool
+type LockedTool struct {
+	Version string `yaml:"version"`
+	URL     string `yaml:"url"`
+	SHA256  string `yaml:"sha256"`
+}
+
+// Lock maps tool names to the versions resolved from a manifest
+type Lock map[string]LockedTool
+
+// LoadLock reads a lockfile from the given path
+func LoadLock(path string) (Lock, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := make(Lock)
+	if err = yaml.Unmarshal(d, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Save writes the lockfile to the given path
+func (l Lock) Save(path string) error {
+	d, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, d, 0644)
+}
+
+// Tools returns the tool names of the lockfile in sorted order
+func (l Lock) Tools() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Manifest maps tool names to version constraints. Ex: terraform: "~1.5"
+type Manifest map[string]string
+
+// Load reads a manifest from the given path
+func Load(path string) (Manifest, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(Manifest)
+	if err = yaml.Unmarshal(d, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Tools returns the tool names of the manifest in sorted order
+func (m Manifest) Tools() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -18,9 +18,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/cellpointmobile/vk/file"
 )
 
 // Command defines command, version args and regexp to find version number.
@@ -31,20 +34,30 @@ type Command struct {
 	VersionRegexp string
 }
 
+// ResolveShim returns the file a shim at path runs by default, or an empty
+// string if path is not a shim. It is set by the store, which writes the shims.
+var ResolveShim = func(cmd string, path string) string {
+	return ""
+}
+
 // GetLocalVersion runs Cmd with versionArg and finds version using versionRegexp, which it returns.
-func (p *Command) GetLocalVersion() string {
+func (p *Command) GetLocalVersion() (string, error) {
 	args := strings.Split(p.VersionArg, " ")
-	cmd := filepath.Join(p.Path, p.Cmd)
-	version := exec.Command(cmd, args...)
+	version := exec.Command(p.GetExecutable(), args...)
 	versionOut, err := version.CombinedOutput()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing version: %s", err)
-		os.Exit(60)
+		return "", newError(p.Cmd, ErrVersionParse, err)
+	}
+	vr, err := regexp.Compile(p.VersionRegexp)
+	if err != nil {
+		return "", newError(p.Cmd, ErrVersionParse, err)
 	}
-	vr := regexp.MustCompile(p.VersionRegexp)
 	match := vr.FindStringSubmatch(
 		string(versionOut))
-	return match[1]
+	if len(match) < 2 {
+		return "", newError(p.Cmd, ErrVersionParse, fmt.Errorf("no match for %s in output of %s", p.VersionRegexp, p.VersionArg))
+	}
+	return match[1], nil
 }
 
 // IsInstalled checks if command is installed and returns boolean
@@ -64,3 +77,45 @@ func (p *Command) GetCmd() string {
 func (p *Command) GetFullPath() string {
 	return filepath.Join(p.Path, p.Cmd)
 }
+
+// GetExecutable returns the file the command in the bindir runs. For a shim,
+// that is the version it runs by default, and not the version selected for the
+// current directory.
+func (p *Command) GetExecutable() string {
+	if f := ResolveShim(p.Cmd, p.GetFullPath()); f != "" {
+		return f
+	}
+	return p.GetFullPath()
+}
+
+// SetPath sets the directory the command is installed to
+func (p *Command) SetPath(path string) {
+	p.Path = path
+}
+
+// GetFilesPath returns the directory other files of the program are extracted to
+func (p *Command) GetFilesPath() string {
+	return FilesPath(p.GetFullPath())
+}
+
+// FilesPath returns the directory of the files extracted besides the command at
+// path. The store moves them into the version directory, the prefix of the program.
+func FilesPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".files")
+}
+
+// targets returns what to extract from an archive: filename as the command,
+// and files into the prefix of the program
+func (p *Command) targets(rx *strings.Replacer, filename string, files []file.Target) []file.Target {
+	targets := []file.Target{{Source: rx.Replace(filename), Destination: p.GetFullPath(), Mode: 0755}}
+	for _, f := range files {
+		d := rx.Replace(f.Destination)
+		// Destinations are kept within the prefix
+		dest := filepath.Join(p.GetFilesPath(), filepath.FromSlash(path.Clean("/"+d)))
+		if d == "" || strings.HasSuffix(d, "/") {
+			dest += string(filepath.Separator)
+		}
+		targets = append(targets, file.Target{Source: rx.Replace(f.Source), Destination: dest})
+	}
+	return targets
+}
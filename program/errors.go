@@ -0,0 +1,62 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by programs. Use errors.Is to check for them.
+var (
+	ErrRateLimited     = errors.New("rate limit hit on API, please add personal API token")
+	ErrVersionLookup   = errors.New("can't get latest version")
+	ErrVersionNotFound = errors.New("can't find version")
+	ErrVersionParse    = errors.New("can't parse version")
+	ErrAssetNotFound   = errors.New("can't find asset")
+	ErrChecksum        = errors.New("can't verify checksum")
+	ErrDownload        = errors.New("can't download")
+	ErrExtract         = errors.New("can't extract")
+	ErrInstall         = errors.New("can't install")
+)
+
+// Error is an error of a specific kind that happened for a program
+type Error struct {
+	Cmd  string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", e.Cmd, e.Kind)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Cmd, e.Kind, e.Err)
+}
+
+// Is makes errors.Is match the kind of error
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns an Error of the given kind for cmd
+func newError(cmd string, kind error, err error) error {
+	return &Error{Cmd: cmd, Kind: kind, Err: err}
+}
@@ -16,8 +16,13 @@ package program
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/Masterminds/semver"
 	"github.com/google/go-github/github"
@@ -30,31 +35,119 @@ import (
 // ClearCache variable for clear-cache flag
 var ClearCache bool
 
+// clearGithubCache makes sure the Github cache is only cleared once, even with concurrent clients
+var clearGithubCache sync.Once
+
 // IsLatestVersion returns true if installed program is latest version.
-func IsLatestVersion(p IProgram) bool {
+func IsLatestVersion(p IProgram) (bool, error) {
 	if !p.IsInstalled() {
-		return false
+		return false, nil
+	}
+	loV, err := p.GetLocalVersion()
+	if err != nil {
+		return false, err
 	}
-	loV := p.GetLocalVersion()
 	laV, _, err := p.GetLatestVersion()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't get latest version.")
-		os.Exit(10)
+		return false, err
 	}
 	localVersion, err := semver.NewVersion(loV)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: Can not parse local version: '%s'\n", p.GetCmd(), loV)
-		panic(err)
+		return false, newError(p.GetCmd(), ErrVersionParse, fmt.Errorf("local version '%s': %s", loV, err))
 	}
 	latestVersion, err := semver.NewVersion(laV)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: Can not parse latest version: '%s'\n", p.GetCmd(), laV)
-		panic(err)
+		return false, newError(p.GetCmd(), ErrVersionParse, fmt.Errorf("latest version '%s': %s", laV, err))
 	}
 	if localVersion.LessThan(latestVersion) {
-		return false
+		return false, nil
+	}
+	return true, nil
+}
+
+var constraintOperator = regexp.MustCompile(`^(=|!=|>|<|>=|=>|<=|=<|~|~>|\^)$`)
+var partialUpperBound = regexp.MustCompile(`^<v?\d+(\.\d+)?$`)
+
+// NewConstraint parses a version constraint. Besides the syntax of the semver
+// library, constraints may be separated by whitespace, ex: ">=3.10 <4".
+func NewConstraint(c string) (*semver.Constraints, error) {
+	ors := strings.Split(c, "||")
+	for i, or := range ors {
+		fields := strings.Fields(strings.Replace(or, ",", " ", -1))
+		var ands []string
+		for j := 0; j < len(fields); j++ {
+			f := fields[j]
+			switch {
+			case constraintOperator.MatchString(f) && j+1 < len(fields):
+				// Operator separated from its version, ex: ">= 3.10"
+				f += fields[j+1]
+				j++
+			case f == "-" && len(ands) > 0 && j+1 < len(fields):
+				// Hyphen range, ex: "1.2 - 1.4"
+				ands[len(ands)-1] += " - " + fields[j+1]
+				j++
+				continue
+			}
+			if partialUpperBound.MatchString(f) {
+				// The semver library reads "<4" as "<4.x", pad it so 4.0.0 is excluded
+				f += strings.Repeat(".0", 2-strings.Count(f, "."))
+			}
+			ands = append(ands, f)
+		}
+		ors[i] = strings.Join(ands, ",")
+	}
+	return semver.NewConstraint(strings.Join(ors, " || "))
+}
+
+// MatchesConstraint returns true if version satisfies the constraint
+func MatchesConstraint(version string, constraint string) (bool, error) {
+	c, err := NewConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
+
+// FindVersion returns the newest available version of the program satisfying the constraint
+func FindVersion(p IProgram, constraint string) (string, error) {
+	c, err := NewConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	versions, err := p.ListVersions()
+	if err != nil {
+		return "", err
+	}
+	v := newestVersion(versions, c)
+	if v == "" {
+		return "", newError(p.GetCmd(), ErrVersionNotFound, errors.New("no version matches "+constraint))
+	}
+	return v, nil
+}
+
+// newestVersion returns the newest of the versions satisfying the constraint,
+// or an empty string if none do
+func newestVersion(versions []string, c *semver.Constraints) string {
+	var matching semver.Collection
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			// Skip releases not following semver
+			continue
+		}
+		if c.Check(v) {
+			matching = append(matching, v)
+		}
+	}
+	if len(matching) == 0 {
+		return ""
 	}
-	return true
+	sort.Sort(matching)
+	return matching[len(matching)-1].Original()
 }
 
 // NewGithubClient returns github.Client with auth if available otherwise unauthenticated
@@ -64,7 +157,9 @@ func NewGithubClient() (*github.Client, context.Context) {
 	var ctx context.Context
 	githubCache := os.ExpandEnv("$HOME/.vk/github-cache")
 	if ClearCache {
-		os.RemoveAll(githubCache)
+		clearGithubCache.Do(func() {
+			os.RemoveAll(githubCache)
+		})
 	}
 	cacheclient := httpcache.NewTransport(diskcache.New(githubCache)).Client()
 	if githubAPIToken != "" {
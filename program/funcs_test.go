@@ -0,0 +1,62 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+func TestNewConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		matching   []string
+		other      []string
+	}{
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{">=3.10 <4", []string{"3.10.0", "3.12.1"}, []string{"3.9.9", "4.0.0", "4.1.0"}},
+		{">= 3.10, < 4", []string{"3.10.0", "3.12.1"}, []string{"3.9.9", "4.0.0"}},
+		{">=3.10,<4", []string{"3.10.0", "3.12.1"}, []string{"3.9.9", "4.0.0"}},
+		{"<4.1", []string{"4.0.9"}, []string{"4.1.0"}},
+		{"< v4", []string{"3.99.0"}, []string{"4.0.0"}},
+		{"1.2 - 1.4", []string{"1.2.0", "1.4.0"}, []string{"1.1.9", "1.5.0"}},
+		{"~1.2 || ^2", []string{"1.2.9", "2.5.0"}, []string{"1.3.0", "3.0.0"}},
+		{"~> 1.2.0 || >= 3", []string{"1.2.5", "3.1.0"}, []string{"1.3.0", "2.0.0"}},
+		{"!= 1.0.0", []string{"1.0.1"}, []string{"1.0.0"}},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := NewConstraint(tc.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range tc.matching {
+				if !c.Check(semver.MustParse(v)) {
+					t.Errorf("%s doesn't match", v)
+				}
+			}
+			for _, v := range tc.other {
+				if c.Check(semver.MustParse(v)) {
+					t.Errorf("%s matches", v)
+				}
+			}
+		})
+	}
+	for _, c := range []string{"", "foo", ">= ", "1.2 -"} {
+		if _, err := NewConstraint(c); err == nil {
+			t.Errorf("NewConstraint(%q) succeeded", c)
+		}
+	}
+}
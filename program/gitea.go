@@ -0,0 +1,269 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/gregjones/httpcache/diskcache"
+	"github.com/spf13/viper"
+)
+
+// clearGiteaCache makes sure the Gitea cache is only cleared once, even with concurrent clients
+var clearGiteaCache sync.Once
+
+// giteaPageSize is the number of releases requested per page, which is the default maximum of Gitea
+const giteaPageSize = 50
+
+// GiteaProgram is a Program released via Gitea or Forgejo, ex: on Codeberg
+type GiteaProgram struct {
+	Command
+	Platform
+	GiteaURL    string // Optional, base URL of the Gitea instance. Defaults to the gitea-url config, or https://codeberg.org
+	GiteaOwner  string
+	GiteaRepo   string
+	ReleaseName string // Name of the release asset. Ex: tool_{VERSION}_{OS}_{ARCH}.tar.gz
+	DownloadURL string // Optional, will be used instead of the URL of the asset.
+	PreRelease  bool   // Accept prereleases. Defaults to false
+	TagName     string // Optional, will be used to find release. Ex: kustomize will find kustomize/v3.3.0. Used when multiple programs are released from the same repo.
+
+	ChecksumAsset string // Optional, name of release asset with SHA-256 checksums. Ex: checksums.txt
+	ChecksumURL   string // Optional, URL of SHA-256 checksums. Will be used instead of ChecksumAsset.
+}
+
+// GiteaDirectDownloadProgram downloads a file directly
+type GiteaDirectDownloadProgram struct {
+	GiteaProgram
+}
+
+// GiteaDownloadUntarFileProgram downloads a tarball and extracts files from it
+type GiteaDownloadUntarFileProgram struct {
+	GiteaProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// GiteaDownloadUnzipFileProgram downloads a zip-file and extracts files from it
+type GiteaDownloadUnzipFileProgram struct {
+	GiteaProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// giteaRelease is a release returned by the Gitea API
+type giteaRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	PreRelease bool   `json:"prerelease"`
+	Assets     []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assets returns the assets of the release
+func (r *giteaRelease) assets() []asset {
+	assets := make([]asset, 0, len(r.Assets))
+	for _, a := range r.Assets {
+		assets = append(assets, asset{Name: a.Name, URL: a.BrowserDownloadURL})
+	}
+	return assets
+}
+
+// getBaseURL returns the URL of the Gitea instance
+func (p *GiteaProgram) getBaseURL() string {
+	u := p.GiteaURL
+	if u == "" {
+		u = viper.GetString("gitea-url")
+	}
+	if u == "" {
+		u = "https://codeberg.org"
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// giteaGet gets a path of the releases API of the repo and decodes the JSON response into v
+func (p *GiteaProgram) giteaGet(apiPath string, v interface{}) (*http.Response, error) {
+	giteaCache := os.ExpandEnv("$HOME/.vk/gitea-cache")
+	if ClearCache {
+		clearGiteaCache.Do(func() {
+			os.RemoveAll(giteaCache)
+		})
+	}
+	// Requests are sent with the retries and timeouts of downloads
+	file.SetCache(p.getBaseURL()+"/api/", diskcache.New(giteaCache))
+	u := p.getBaseURL() + "/api/v1/repos/" + url.PathEscape(p.GiteaOwner) + "/" + url.PathEscape(p.GiteaRepo) + "/releases" + apiPath
+	if token := viper.GetString("gitea-api-token"); token != "" {
+		// Release assets of private repos are also downloaded with the token
+		file.SetHeader(p.getBaseURL()+"/", "Authorization", "token "+token)
+	}
+	resp, err := file.Get(u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// giteaError returns an Error of the given kind, or ErrRateLimited if the Gitea rate limit was hit
+func (p *GiteaProgram) giteaError(kind error, resp *http.Response, err error) error {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return newError(p.Cmd, ErrRateLimited, err)
+	}
+	return newError(p.Cmd, kind, err)
+}
+
+// isMatchingRelease returns true if the release matches PreRelease and TagName of the program
+func (p *GiteaProgram) isMatchingRelease(r *giteaRelease) bool {
+	if r.Draft || r.PreRelease != p.PreRelease {
+		return false
+	}
+	return p.TagName == "" || strings.HasPrefix(r.TagName, p.TagName)
+}
+
+// getReleaseURL returns the version and download URL of a release
+func (p *GiteaProgram) getReleaseURL(r *giteaRelease) (string, string, error) {
+	v := versionFromTag(p.TagName, r.TagName)
+	rx := p.replacer("{VERSION}", v)
+	if p.DownloadURL != "" {
+		return v, rx.Replace(p.DownloadURL), nil
+	}
+	a, err := findAsset(r.assets(), rx.Replace(p.ReleaseName))
+	if err != nil {
+		return "", "", newError(p.Cmd, ErrAssetNotFound, err)
+	}
+	return v, a.URL, nil
+}
+
+// getChecksum returns the published SHA-256 checksum of the download URL.
+// Returns an empty string if the program has no checksums defined.
+func (p *GiteaProgram) getChecksum(v string, u string) (string, error) {
+	return releaseChecksum(p.Cmd, p.replacer("{VERSION}", v), p.ChecksumURL, p.ChecksumAsset, u)
+}
+
+// GetSource returns the type of source the program is released from
+func (p *GiteaProgram) GetSource() string {
+	return "gitea"
+}
+
+// GetLatestVersion returns the latest version available
+func (p *GiteaProgram) GetLatestVersion() (string, string, error) {
+	// Releases are listed newest first, so the first matching release is the latest
+	for page := 1; ; page++ {
+		var releases []giteaRelease
+		resp, err := p.giteaGet(fmt.Sprintf("?limit=%d&page=%d", giteaPageSize, page), &releases)
+		if err != nil {
+			return "", "", p.giteaError(ErrVersionLookup, resp, err)
+		}
+		for i := range releases {
+			if p.isMatchingRelease(&releases[i]) {
+				return p.getReleaseURL(&releases[i])
+			}
+		}
+		if len(releases) < giteaPageSize {
+			return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no matching release"))
+		}
+	}
+}
+
+// ListVersions returns all released versions
+func (p *GiteaProgram) ListVersions() ([]string, error) {
+	var versions []string
+	for page := 1; ; page++ {
+		var releases []giteaRelease
+		resp, err := p.giteaGet(fmt.Sprintf("?limit=%d&page=%d", giteaPageSize, page), &releases)
+		if err != nil {
+			return nil, p.giteaError(ErrVersionLookup, resp, err)
+		}
+		for i := range releases {
+			if p.isMatchingRelease(&releases[i]) {
+				versions = append(versions, versionFromTag(p.TagName, releases[i].TagName))
+			}
+		}
+		if len(releases) < giteaPageSize {
+			break
+		}
+	}
+	return versions, nil
+}
+
+// GetVersion returns the given version and its download URL, if it has been released
+func (p *GiteaProgram) GetVersion(version string) (string, string, error) {
+	for _, tag := range candidateTags(p.TagName, version) {
+		var r giteaRelease
+		resp, err := p.giteaGet("/tags/"+url.QueryEscape(tag), &r)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return "", "", p.giteaError(ErrVersionLookup, resp, err)
+		}
+		return p.getReleaseURL(&r)
+	}
+	return "", "", newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no release of version %s", version))
+}
+
+// DownloadLatestVersion downloads the latest release and puts it into the bindir
+func (p *GiteaDirectDownloadProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads the given release and puts it into the bindir
+func (p *GiteaDirectDownloadProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GiteaDirectDownloadProgram) download(v string, u string) error {
+	return downloadFile(p, v, u)
+}
+
+// DownloadLatestVersion downloads and untars a file to the bindir
+func (p *GiteaDownloadUntarFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and untars the given version to the bindir
+func (p *GiteaDownloadUntarFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GiteaDownloadUntarFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromTar, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
+
+// DownloadLatestVersion downloads and unzips a file to the bindir
+func (p *GiteaDownloadUnzipFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and unzips the given version to the bindir
+func (p *GiteaDownloadUnzipFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GiteaDownloadUnzipFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromZip, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
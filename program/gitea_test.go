@@ -0,0 +1,181 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/spf13/viper"
+)
+
+// giteaTestRelease is a release served by fakeGitea
+type giteaTestRelease struct {
+	tag        string
+	draft      bool
+	prerelease bool
+}
+
+// fakeGitea serves the releases of the repo owner/tool, newest first, in pages of the requested limit
+func fakeGitea(t *testing.T, releases []giteaTestRelease) *httptest.Server {
+	var s *httptest.Server
+	release := func(r giteaTestRelease) map[string]interface{} {
+		v := r.tag[1:]
+		download := s.URL + "/owner/tool/releases/download/" + r.tag + "/"
+		return map[string]interface{}{
+			"tag_name":   r.tag,
+			"draft":      r.draft,
+			"prerelease": r.prerelease,
+			"assets": []map[string]string{
+				{"name": "tool-" + v, "browser_download_url": download + "tool-" + v},
+				{"name": "checksums.txt", "browser_download_url": download + "checksums.txt"},
+			},
+		}
+	}
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Both the API and the private release assets require the token
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		const api = "/api/v1/repos/owner/tool/releases"
+		switch {
+		case r.URL.Path == api:
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			list := []map[string]interface{}{}
+			for i := (page - 1) * limit; i < len(releases) && i < page*limit; i++ {
+				list = append(list, release(releases[i]))
+			}
+			json.NewEncoder(w).Encode(list)
+		case strings.HasPrefix(r.URL.Path, api+"/tags/"):
+			for _, rel := range releases {
+				if r.URL.Path == api+"/tags/"+rel.tag {
+					json.NewEncoder(w).Encode(release(rel))
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/owner/tool/releases/download/"):
+			parts := strings.Split(r.URL.Path, "/")
+			tag, name := parts[len(parts)-2], parts[len(parts)-1]
+			if name == "checksums.txt" {
+				fmt.Fprintf(w, "%s  tool-%s\n", sha256Hex(testContent(tag[1:])), tag[1:])
+				return
+			}
+			fmt.Fprint(w, testContent(strings.TrimPrefix(name, "tool-")))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newGiteaProgram(t *testing.T, s *httptest.Server) *GiteaDirectDownloadProgram {
+	bindir := testHome(t)
+	token := viper.GetString("gitea-api-token")
+	t.Cleanup(func() { viper.Set("gitea-api-token", token) })
+	viper.Set("gitea-api-token", "secret")
+	return &GiteaDirectDownloadProgram{GiteaProgram{
+		Command:       Command{Cmd: "tool", Path: bindir},
+		GiteaURL:      s.URL,
+		GiteaOwner:    "owner",
+		GiteaRepo:     "tool",
+		ReleaseName:   "tool-{VERSION}",
+		ChecksumAsset: "checksums.txt",
+	}}
+}
+
+func TestGiteaVersions(t *testing.T) {
+	p := newGiteaProgram(t, fakeGitea(t, []giteaTestRelease{
+		{tag: "v1.3.0", draft: true},
+		{tag: "v1.2.0-rc1", prerelease: true},
+		{tag: "v1.1.0"},
+		{tag: "v1.0.0"},
+	}))
+	v, u, err := p.GetLatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.1.0" || u != p.GiteaURL+"/owner/tool/releases/download/v1.1.0/tool-1.1.0" {
+		t.Errorf("GetLatestVersion() = %s, %s", v, u)
+	}
+	versions, err := p.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"1.1.0", "1.0.0"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("ListVersions() = %v, expected %v", versions, expected)
+	}
+}
+
+func TestGiteaLatestVersionPages(t *testing.T) {
+	// Prereleases fill the first page, so the latest release is on the second
+	var releases []giteaTestRelease
+	for i := giteaPageSize; i > 0; i-- {
+		releases = append(releases, giteaTestRelease{tag: fmt.Sprintf("v2.0.0-rc%d", i), prerelease: true})
+	}
+	releases = append(releases, giteaTestRelease{tag: "v1.0.0"})
+	p := newGiteaProgram(t, fakeGitea(t, releases))
+	v, _, err := p.GetLatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.0.0" {
+		t.Errorf("GetLatestVersion() = %s, expected 1.0.0", v)
+	}
+	p.TagName = "other/"
+	if _, _, err = p.GetLatestVersion(); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("GetLatestVersion() of another tag returned %v, expected %v", err, ErrVersionNotFound)
+	}
+}
+
+func TestGiteaRetries(t *testing.T) {
+	delay := file.RetryDelay
+	t.Cleanup(func() { file.RetryDelay = delay })
+	file.RetryDelay = time.Millisecond
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	}))
+	defer s.Close()
+	p := newGiteaProgram(t, s)
+	p.DownloadURL = s.URL + "/tool-{VERSION}"
+	v, _, err := p.GetLatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.0.0" || requests != 2 {
+		t.Errorf("GetLatestVersion() = %s after %d requests, expected 1.0.0 after a retry", v, requests)
+	}
+}
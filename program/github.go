@@ -15,13 +15,12 @@
 package program
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
 	"strings"
 
-	"github.com/cavaliercoder/grab"
 	"github.com/cellpointmobile/vk/file"
 	"github.com/google/go-github/github"
 )
@@ -29,12 +28,16 @@ import (
 // GithubProgram is a Program released via Github
 type GithubProgram struct {
 	Command
+	Platform
 	GithubOwner string
 	GithubRepo  string
-	ReleaseName string // Will be appended when generating Github download URL. Ex: kustomize_{VERSION}_linux_amd64
+	ReleaseName string // Will be appended when generating Github download URL. Ex: kustomize_{VERSION}_{OS}_{ARCH}
 	DownloadURL string // Optional, will be used instead of generating URL.
 	PreRelease  bool   // Accept prereleases. Defaults to false
 	TagName     string // Optional, will be used to find release. Ex: kustomize will find kustomize/v3.3.0. Used when multiple programs are released from the same repo.
+
+	ChecksumAsset string // Optional, name of release asset with SHA-256 checksums. Ex: checksums.txt
+	ChecksumURL   string // Optional, URL of SHA-256 checksums. Will be used instead of ChecksumAsset.
 }
 
 // GithubDirectDownloadProgram downloads a file directly
@@ -42,152 +45,180 @@ type GithubDirectDownloadProgram struct {
 	GithubProgram
 }
 
-// GithubDownloadUntarFileProgram downloads a tarball and extracts a single file from it
+// GithubDownloadUntarFileProgram downloads a tarball and extracts files from it
 type GithubDownloadUntarFileProgram struct {
 	GithubProgram
 	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
 }
 
-// GithubDownloadUnzipFileProgram downloads a zip-file and extracts a single file from it
+// GithubDownloadUnzipFileProgram downloads a zip-file and extracts files from it
 type GithubDownloadUnzipFileProgram struct {
 	GithubProgram
 	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
 }
 
-func findAsset(r []*github.ReleaseAsset, name string) (*github.ReleaseAsset, error) {
+// githubAssets returns the assets of a Github release
+func githubAssets(r []*github.ReleaseAsset) []asset {
+	assets := make([]asset, 0, len(r))
 	for _, x := range r {
-		if *x.Name == name {
-			return x, nil
+		assets = append(assets, asset{Name: x.GetName(), URL: x.GetBrowserDownloadURL()})
+	}
+	return assets
+}
+
+// githubError returns an Error of the given kind, or ErrRateLimited if the Github rate limit was hit
+func (p *GithubProgram) githubError(kind error, err error) error {
+	if _, ok := err.(*github.RateLimitError); ok {
+		return newError(p.Cmd, ErrRateLimited, err)
+	}
+	return newError(p.Cmd, kind, err)
+}
+
+// getReleaseURL returns the version and download URL of a release
+func (p *GithubProgram) getReleaseURL(client *github.Client, ctx context.Context, r *github.RepositoryRelease) (string, string, error) {
+	var u string
+	v := versionFromTag(p.TagName, r.GetTagName())
+	rx := p.replacer("{VERSION}", v)
+	if p.DownloadURL == "" {
+		rn := rx.Replace(p.ReleaseName)
+		la, _, err := client.Repositories.ListReleaseAssets(ctx, p.GithubOwner, p.GithubRepo, r.GetID(), &github.ListOptions{})
+		if err != nil {
+			return "", "", p.githubError(ErrVersionLookup, err)
+		}
+		a, err := findAsset(githubAssets(la), rn)
+		if err != nil {
+			return "", "", newError(p.Cmd, ErrAssetNotFound, err)
 		}
+		u = a.URL
+	} else {
+		u = rx.Replace(p.DownloadURL)
 	}
-	return nil, errors.New("can't find asset")
+	return v, u, nil
+}
+
+// getChecksum returns the published SHA-256 checksum of the download URL.
+// Returns an empty string if the program has no checksums defined.
+func (p *GithubProgram) getChecksum(v string, u string) (string, error) {
+	return releaseChecksum(p.Cmd, p.replacer("{VERSION}", v), p.ChecksumURL, p.ChecksumAsset, u)
+}
+
+// GetSource returns the type of source the program is released from
+func (p *GithubProgram) GetSource() string {
+	return "github"
 }
 
 // GetLatestVersion returns the latest version available
 func (p *GithubProgram) GetLatestVersion() (string, string, error) {
-	var err error
 	var r *github.RepositoryRelease
-	var u string
-	var v string
 	client, ctx := NewGithubClient()
 	releases, _, err := client.Repositories.ListReleases(ctx, p.GithubOwner, p.GithubRepo, &github.ListOptions{})
-	if _, ok := err.(*github.RateLimitError); ok {
-		fmt.Println("Github rate limit hit, please add personal API token.")
-		return "", "", err
+	if err != nil {
+		return "", "", p.githubError(ErrVersionLookup, err)
 	}
 
 	// Loop through releases and find the correct release based on PreRelease status and TagName prefix
 	for _, release := range releases {
-		if *release.Prerelease == p.PreRelease {
+		if release.GetPrerelease() == p.PreRelease {
 			if p.TagName == "" {
 				r = release
 				break
 			} else {
-				if strings.HasPrefix(*release.TagName, p.TagName) {
+				if strings.HasPrefix(release.GetTagName(), p.TagName) {
 					r = release
 					break
 				}
 			}
 		}
 	}
+	if r == nil {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no matching release"))
+	}
+	return p.getReleaseURL(client, ctx, r)
+}
 
-	// Find version number based on tag
-	if p.TagName == "" {
-		// No TagName prefix specified, just trim away any prefixed "v"
-		v = strings.TrimPrefix(r.GetTagName(), "v")
-	} else {
-		// TagName prefix specified, first trim away TagName, then any remaining prefixed "v"
-		v = strings.TrimPrefix(r.GetTagName(), p.TagName)
-		v = strings.TrimPrefix(v, "/")
+// ListVersions returns all released versions
+func (p *GithubProgram) ListVersions() ([]string, error) {
+	var versions []string
+	client, ctx := NewGithubClient()
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ctx, p.GithubOwner, p.GithubRepo, opt)
+		if err != nil {
+			return nil, p.githubError(ErrVersionLookup, err)
+		}
+		for _, release := range releases {
+			if release.GetPrerelease() && !p.PreRelease {
+				continue
+			}
+			if p.TagName != "" && !strings.HasPrefix(release.GetTagName(), p.TagName) {
+				continue
+			}
+			versions = append(versions, versionFromTag(p.TagName, release.GetTagName()))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
 	}
-	rx := strings.NewReplacer("{VERSION}", v)
-	if p.DownloadURL == "" {
-		rn := rx.Replace(p.ReleaseName)
-		la, _, err := client.Repositories.ListReleaseAssets(ctx, p.GithubOwner, p.GithubRepo, *r.ID, &github.ListOptions{})
-		if _, ok := err.(*github.RateLimitError); ok {
-			fmt.Println("Github rate limit hit, please add personal API token.")
-			return "", "", err
+	return versions, nil
+}
+
+// GetVersion returns the given version and its download URL, if it has been released
+func (p *GithubProgram) GetVersion(version string) (string, string, error) {
+	client, ctx := NewGithubClient()
+	for _, tag := range candidateTags(p.TagName, version) {
+		r, resp, err := client.Repositories.GetReleaseByTag(ctx, p.GithubOwner, p.GithubRepo, tag)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
 		}
-		a, err := findAsset(la, rn)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Error finding asset.")
-			os.Exit(200)
+			return "", "", p.githubError(ErrVersionLookup, err)
 		}
-		u = a.GetBrowserDownloadURL()
-	} else {
-		u = rx.Replace(p.DownloadURL)
+		return p.getReleaseURL(client, ctx, r)
 	}
-	return v, u, err
+	return "", "", newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no release of version %s", version))
 }
 
 // DownloadLatestVersion downloads the latest release and puts it into the bindir
-func (p *GithubDirectDownloadProgram) DownloadLatestVersion() string {
-	f := filepath.Join(p.Path, p.Cmd)
-	v, url, err := p.GetLatestVersion()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: Can't get latest version.", p.Cmd)
-		os.Exit(10)
-	}
-	bak := f + ".bak"
-	os.Rename(f, bak)
-	_, err = grab.Get(f, url)
-	if err != nil {
-		os.Rename(bak, f)
-		fmt.Fprintf(os.Stderr, "Could not download update to %s: %s", p.GetCmd(), err)
-		os.Exit(70)
-	}
-	if err = os.Chmod(f, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting chmod for downloaded file: %s", err)
-		os.Exit(80)
-	}
-	os.Remove(bak)
-	return v
+func (p *GithubDirectDownloadProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads the given release and puts it into the bindir
+func (p *GithubDirectDownloadProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GithubDirectDownloadProgram) download(v string, u string) error {
+	return downloadFile(p, v, u)
 }
 
 // DownloadLatestVersion downloads and untars a file to the bindir
-func (p *GithubDownloadUntarFileProgram) DownloadLatestVersion() string {
-	f := filepath.Join(p.Path, p.Cmd)
-	v, url, err := p.GetLatestVersion()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't get latest version.")
-		os.Exit(10)
-	}
-	rx := strings.NewReplacer("{VERSION}", v)
-	err = file.ExtractFromTar(
-		url,
-		rx.Replace(p.Filename),
-		f)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error extracting file from tarball: %s", err)
-		os.Exit(90)
-	}
-	if err = os.Chmod(f, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting chmod for downloaded file: %s", err)
-		os.Exit(80)
-	}
-	return v
+func (p *GithubDownloadUntarFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and untars the given version to the bindir
+func (p *GithubDownloadUntarFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GithubDownloadUntarFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromTar, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
 }
 
 // DownloadLatestVersion downloads and unzips a file to the bindir
-func (p *GithubDownloadUnzipFileProgram) DownloadLatestVersion() string {
-	f := filepath.Join(p.Path, p.Cmd)
-	v, url, err := p.GetLatestVersion()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't get latest version.")
-		os.Exit(10)
-	}
-	rx := strings.NewReplacer("{VERSION}", v)
-	err = file.ExtractFromZip(
-		url,
-		rx.Replace(p.Filename),
-		f)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error extracting file from zip: %s", err)
-		os.Exit(90)
-	}
-	if err = os.Chmod(f, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting chmod for downloaded file: %s", err)
-		os.Exit(80)
-	}
-	return v
+func (p *GithubDownloadUnzipFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and unzips the given version to the bindir
+func (p *GithubDownloadUnzipFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GithubDownloadUnzipFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromZip, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
 }
@@ -0,0 +1,328 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/file"
+	"github.com/gregjones/httpcache/diskcache"
+	"github.com/spf13/viper"
+)
+
+// clearGitlabCache makes sure the GitLab cache is only cleared once, even with concurrent clients
+var clearGitlabCache sync.Once
+
+// GitlabProgram is a Program released via GitLab
+type GitlabProgram struct {
+	Command
+	Platform
+	GitlabURL     string // Optional, base URL of the GitLab instance. Defaults to the gitlab-url config, or https://gitlab.com
+	GitlabProject string // Path or ID of the project. Ex: gitlab-org/cli
+	ReleaseName   string // Name of the release asset link. Ex: glab_{VERSION}_{OS}_{ARCH}.tar.gz
+	DownloadURL   string // Optional, will be used instead of the URL of the asset link.
+	PreRelease    bool   // Accept prereleases, which are versions with a semver prerelease part, ex: 1.2.0-rc1. Defaults to false
+	TagName       string // Optional, will be used to find release. Ex: kustomize will find kustomize/v3.3.0. Used when multiple programs are released from the same project.
+
+	ChecksumAsset string // Optional, name of release asset link with SHA-256 checksums. Ex: checksums.txt
+	ChecksumURL   string // Optional, URL of SHA-256 checksums. Will be used instead of ChecksumAsset.
+}
+
+// GitlabDirectDownloadProgram downloads a file directly
+type GitlabDirectDownloadProgram struct {
+	GitlabProgram
+}
+
+// GitlabDownloadUntarFileProgram downloads a tarball and extracts files from it
+type GitlabDownloadUntarFileProgram struct {
+	GitlabProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// GitlabDownloadUnzipFileProgram downloads a zip-file and extracts files from it
+type GitlabDownloadUnzipFileProgram struct {
+	GitlabProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// gitlabRelease is a release returned by the GitLab Releases API
+type gitlabRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+// assets returns the asset links of the release
+func (r *gitlabRelease) assets() []asset {
+	assets := make([]asset, 0, len(r.Assets.Links))
+	for _, l := range r.Assets.Links {
+		u := l.DirectAssetURL
+		if u == "" {
+			u = l.URL
+		}
+		assets = append(assets, asset{Name: l.Name, URL: u})
+	}
+	return assets
+}
+
+// getBaseURL returns the URL of the GitLab instance
+func (p *GitlabProgram) getBaseURL() string {
+	u := p.GitlabURL
+	if u == "" {
+		u = viper.GetString("gitlab-url")
+	}
+	if u == "" {
+		u = "https://gitlab.com"
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// gitlabGet gets a path of the Releases API of the project and decodes the JSON response into v
+func (p *GitlabProgram) gitlabGet(apiPath string, v interface{}) (*http.Response, error) {
+	gitlabCache := os.ExpandEnv("$HOME/.vk/gitlab-cache")
+	if ClearCache {
+		clearGitlabCache.Do(func() {
+			os.RemoveAll(gitlabCache)
+		})
+	}
+	// Requests are sent with the retries and timeouts of downloads
+	file.SetCache(p.getBaseURL()+"/api/", diskcache.New(gitlabCache))
+	u := p.getBaseURL() + "/api/v4/projects/" + url.QueryEscape(p.GitlabProject) + "/releases" + apiPath
+	if token := viper.GetString("gitlab-api-token"); token != "" {
+		// Release assets of private projects are also downloaded with the token
+		file.SetHeader(p.getBaseURL()+"/", "Authorization", "Bearer "+token)
+	}
+	resp, err := file.Get(u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// gitlabReleases lists the releases of the project page by page, until visit returns false
+func (p *GitlabProgram) gitlabReleases(query string, visit func(r *gitlabRelease) bool) error {
+	for apiPath := "?" + query; apiPath != ""; {
+		var releases []gitlabRelease
+		resp, err := p.gitlabGet(apiPath, &releases)
+		if err != nil {
+			return p.gitlabError(ErrVersionLookup, resp, err)
+		}
+		for i := range releases {
+			if !visit(&releases[i]) {
+				return nil
+			}
+		}
+		apiPath = gitlabNextPage(query, resp)
+	}
+	return nil
+}
+
+// gitlabNextPage returns the API path of the next page of a list, or an empty
+// string on the last page. The Link header is used with keyset pagination, and
+// X-Next-Page with offset pagination.
+func gitlabNextPage(query string, resp *http.Response) string {
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		parts := strings.Split(link, ";")
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) != `rel="next"` {
+				continue
+			}
+			u, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+			if err == nil {
+				return "?" + u.RawQuery
+			}
+		}
+	}
+	if page := resp.Header.Get("X-Next-Page"); page != "" {
+		return "?" + query + "&page=" + url.QueryEscape(page)
+	}
+	return ""
+}
+
+// gitlabError returns an Error of the given kind, or ErrRateLimited if the GitLab rate limit was hit
+func (p *GitlabProgram) gitlabError(kind error, resp *http.Response, err error) error {
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return newError(p.Cmd, ErrRateLimited, err)
+	}
+	return newError(p.Cmd, kind, err)
+}
+
+// isMatchingRelease returns true if the release matches PreRelease and TagName of the program
+func (p *GitlabProgram) isMatchingRelease(r *gitlabRelease) bool {
+	if p.TagName != "" && !strings.HasPrefix(r.TagName, p.TagName) {
+		return false
+	}
+	// GitLab has no prerelease flag, so prereleases are found by their version
+	v, err := semver.NewVersion(versionFromTag(p.TagName, r.TagName))
+	isPreRelease := err == nil && v.Prerelease() != ""
+	return isPreRelease == p.PreRelease
+}
+
+// getReleaseURL returns the version and download URL of a release
+func (p *GitlabProgram) getReleaseURL(r *gitlabRelease) (string, string, error) {
+	v := versionFromTag(p.TagName, r.TagName)
+	rx := p.replacer("{VERSION}", v)
+	if p.DownloadURL != "" {
+		return v, rx.Replace(p.DownloadURL), nil
+	}
+	a, err := findAsset(r.assets(), rx.Replace(p.ReleaseName))
+	if err != nil {
+		return "", "", newError(p.Cmd, ErrAssetNotFound, err)
+	}
+	return v, a.URL, nil
+}
+
+// getRelease returns the release of the given version
+func (p *GitlabProgram) getRelease(version string) (*gitlabRelease, error) {
+	for _, tag := range candidateTags(p.TagName, version) {
+		var r gitlabRelease
+		resp, err := p.gitlabGet("/"+url.QueryEscape(tag), &r)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, p.gitlabError(ErrVersionLookup, resp, err)
+		}
+		return &r, nil
+	}
+	return nil, newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no release of version %s", version))
+}
+
+// getChecksum returns the published SHA-256 checksum of the download URL.
+// Returns an empty string if the program has no checksums defined.
+func (p *GitlabProgram) getChecksum(v string, u string) (string, error) {
+	if p.ChecksumURL != "" || p.ChecksumAsset == "" {
+		return releaseChecksum(p.Cmd, p.replacer("{VERSION}", v), p.ChecksumURL, "", u)
+	}
+	// Asset links may point anywhere, so the checksum asset is looked up in the release
+	r, err := p.getRelease(v)
+	if err != nil {
+		return "", err
+	}
+	a, err := findAsset(r.assets(), p.replacer("{VERSION}", v).Replace(p.ChecksumAsset))
+	if err != nil {
+		return "", newError(p.Cmd, ErrChecksum, err)
+	}
+	return fetchChecksum(p.Cmd, a.URL, u)
+}
+
+// GetSource returns the type of source the program is released from
+func (p *GitlabProgram) GetSource() string {
+	return "gitlab"
+}
+
+// GetLatestVersion returns the latest version available
+func (p *GitlabProgram) GetLatestVersion() (string, string, error) {
+	var latest *gitlabRelease
+	err := p.gitlabReleases("order_by=released_at&sort=desc&per_page=100", func(r *gitlabRelease) bool {
+		if p.isMatchingRelease(r) {
+			latest = r
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if latest == nil {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no matching release"))
+	}
+	return p.getReleaseURL(latest)
+}
+
+// ListVersions returns all released versions
+func (p *GitlabProgram) ListVersions() ([]string, error) {
+	var versions []string
+	err := p.gitlabReleases("per_page=100", func(r *gitlabRelease) bool {
+		if p.isMatchingRelease(r) {
+			versions = append(versions, versionFromTag(p.TagName, r.TagName))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetVersion returns the given version and its download URL, if it has been released
+func (p *GitlabProgram) GetVersion(version string) (string, string, error) {
+	r, err := p.getRelease(version)
+	if err != nil {
+		return "", "", err
+	}
+	return p.getReleaseURL(r)
+}
+
+// DownloadLatestVersion downloads the latest release and puts it into the bindir
+func (p *GitlabDirectDownloadProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads the given release and puts it into the bindir
+func (p *GitlabDirectDownloadProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GitlabDirectDownloadProgram) download(v string, u string) error {
+	return downloadFile(p, v, u)
+}
+
+// DownloadLatestVersion downloads and untars a file to the bindir
+func (p *GitlabDownloadUntarFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and untars the given version to the bindir
+func (p *GitlabDownloadUntarFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GitlabDownloadUntarFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromTar, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
+
+// DownloadLatestVersion downloads and unzips a file to the bindir
+func (p *GitlabDownloadUnzipFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and unzips the given version to the bindir
+func (p *GitlabDownloadUnzipFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *GitlabDownloadUnzipFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromZip, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
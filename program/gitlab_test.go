@@ -0,0 +1,199 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cellpointmobile/vk/file"
+	"github.com/spf13/viper"
+)
+
+// testHome sets HOME to a temporary directory, so API caches start out empty,
+// and returns it
+func testHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return home
+}
+
+// testContent returns the content of a fake download
+func testContent(name string) string {
+	return "#!/bin/sh\necho " + name + "\n"
+}
+
+// sha256Hex returns the SHA-256 checksum of s
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// fakeGitlab serves the releases of a project, newest first, with pages of two releases
+func fakeGitlab(t *testing.T, tags []string, keyset bool) *httptest.Server {
+	var s *httptest.Server
+	release := func(tag string) map[string]interface{} {
+		v := tag[1:]
+		return map[string]interface{}{
+			"tag_name": tag,
+			"assets": map[string]interface{}{
+				"links": []map[string]string{
+					{"name": "tool-" + v, "url": s.URL + "/other", "direct_asset_url": s.URL + "/downloads/" + tag + "/tool-" + v},
+					{"name": "checksums.txt", "url": s.URL + "/downloads/" + tag + "/checksums.txt"},
+				},
+			},
+		}
+	}
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		const api = "/api/v4/projects/group%2Ftool/releases"
+		p := r.URL.EscapedPath()
+		switch {
+		case p == api:
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if keyset {
+				page, _ = strconv.Atoi(r.URL.Query().Get("cursor"))
+			}
+			if page == 0 {
+				page = 1
+			}
+			var releases []map[string]interface{}
+			for i := (page - 1) * 2; i < len(tags) && i < page*2; i++ {
+				releases = append(releases, release(tags[i]))
+			}
+			if page*2 < len(tags) {
+				if keyset {
+					w.Header().Set("Link", fmt.Sprintf(`<%s%s?order_by=released_at&per_page=2&cursor=%d>; rel="next"`, s.URL, api, page+1))
+				} else {
+					w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+				}
+			}
+			json.NewEncoder(w).Encode(releases)
+		case len(p) > len(api) && p[:len(api)] == api:
+			for _, tag := range tags {
+				if p == api+"/"+tag {
+					json.NewEncoder(w).Encode(release(tag))
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			var tag, name string
+			if _, err := fmt.Sscanf(p, "/downloads/%s", &tag); err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			tag, name = path.Split(tag)
+			if name == "checksums.txt" {
+				v := path.Base(tag)[1:]
+				fmt.Fprintf(w, "%s  tool-%s\n", sha256Hex(testContent(v)), v)
+				return
+			}
+			fmt.Fprint(w, testContent(name[len("tool-"):]))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newGitlabProgram(t *testing.T, s *httptest.Server) *GitlabDirectDownloadProgram {
+	bindir := testHome(t)
+	token := viper.GetString("gitlab-api-token")
+	t.Cleanup(func() { viper.Set("gitlab-api-token", token) })
+	viper.Set("gitlab-api-token", "secret")
+	return &GitlabDirectDownloadProgram{GitlabProgram{
+		Command:       Command{Cmd: "tool", Path: bindir},
+		GitlabURL:     s.URL,
+		GitlabProject: "group/tool",
+		ReleaseName:   "tool-{VERSION}",
+		ChecksumAsset: "checksums.txt",
+	}}
+}
+
+func TestGitlabVersions(t *testing.T) {
+	// Prereleases fill the first page, so the latest release is on the second
+	tags := []string{"v2.0.0-rc2", "v2.0.0-rc1", "v1.2.0", "v1.1.0", "v1.0.0"}
+	for _, keyset := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keyset=%t", keyset), func(t *testing.T) {
+			p := newGitlabProgram(t, fakeGitlab(t, tags, keyset))
+			v, u, err := p.GetLatestVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != "1.2.0" || u != p.GitlabURL+"/downloads/v1.2.0/tool-1.2.0" {
+				t.Errorf("GetLatestVersion() = %s, %s", v, u)
+			}
+			versions, err := p.ListVersions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"1.2.0", "1.1.0", "1.0.0"}; !reflect.DeepEqual(versions, expected) {
+				t.Errorf("ListVersions() = %v, expected %v", versions, expected)
+			}
+			p.PreRelease = true
+			versions, err = p.ListVersions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := []string{"2.0.0-rc2", "2.0.0-rc1"}; !reflect.DeepEqual(versions, expected) {
+				t.Errorf("ListVersions() of prereleases = %v, expected %v", versions, expected)
+			}
+		})
+	}
+}
+
+func TestGitlabRetriesAndCaches(t *testing.T) {
+	delay := file.RetryDelay
+	t.Cleanup(func() { file.RetryDelay = delay })
+	file.RetryDelay = time.Millisecond
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, `[{"tag_name": "v1.0.0"}]`)
+	}))
+	defer s.Close()
+	p := newGitlabProgram(t, s)
+	for i := 0; i < 2; i++ {
+		versions, err := p.ListVersions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 1 || versions[0] != "1.0.0" {
+			t.Errorf("ListVersions() = %v", versions)
+		}
+	}
+	// The failed request is retried, and the second list is cached
+	if requests != 2 {
+		t.Errorf("sent %d requests, expected 2", requests)
+	}
+}
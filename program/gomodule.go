@@ -0,0 +1,215 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"unicode"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/file"
+)
+
+// GoModuleProgram is a Go program built from source with go install
+type GoModuleProgram struct {
+	Command
+	Module     string // Module path, used to look up versions. Ex: golang.org/x/tools/gopls
+	Package    string // Optional, path of the main package. Defaults to Module. Ex: github.com/owner/repo/cmd/tool
+	TagName    string // Optional, prefix of the versions to use, as versions in the module proxy have no tag prefixes. Ex: v1. stays on major version 1
+	PreRelease bool   // Accept prereleases. Defaults to false
+
+	proxy string
+}
+
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// getProxy returns the first module proxy of GOPROXY, defaulting to proxy.golang.org
+func (p *GoModuleProgram) getProxy() string {
+	if p.proxy != "" {
+		return p.proxy
+	}
+	p.proxy = "https://proxy.golang.org"
+	for _, proxy := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if proxy != "direct" && proxy != "off" {
+			p.proxy = strings.TrimSuffix(proxy, "/")
+			break
+		}
+	}
+	return p.proxy
+}
+
+// getModuleURL returns the URL of the module in the module proxy, where
+// upper case letters are escaped as ! followed by the lower case letter
+func (p *GoModuleProgram) getModuleURL() string {
+	var b strings.Builder
+	for _, r := range p.Module {
+		if unicode.IsUpper(r) {
+			b.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return p.getProxy() + "/" + b.String()
+}
+
+// proxyGet gets a path of the module in the module proxy. Returns nil if it does not exist.
+func (p *GoModuleProgram) proxyGet(apiPath string) ([]byte, error) {
+	resp, err := file.Get(p.getModuleURL()+apiPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// The module proxy protocol uses 404 and 410 for modules and versions that do not exist
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", p.getModuleURL()+apiPath, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// getZipURL returns the URL of the source of a version in the module proxy
+func (p *GoModuleProgram) getZipURL(v string) string {
+	return p.getModuleURL() + "/@v/v" + v + ".zip"
+}
+
+// GetSource returns the type of source the program is released from
+func (p *GoModuleProgram) GetSource() string {
+	return "go"
+}
+
+// ListVersions returns the tagged versions of the module
+func (p *GoModuleProgram) ListVersions() ([]string, error) {
+	d, err := p.proxyGet("/@v/list")
+	if err != nil {
+		return nil, newError(p.Cmd, ErrVersionLookup, err)
+	}
+	var versions []string
+	for _, v := range strings.Fields(string(d)) {
+		if strings.HasPrefix(v, p.TagName) {
+			versions = append(versions, strings.TrimPrefix(v, "v"))
+		}
+	}
+	return versions, nil
+}
+
+// GetLatestVersion returns the highest tagged version of the module, or the
+// version the module proxy reports as latest if there are no tags
+func (p *GoModuleProgram) GetLatestVersion() (string, string, error) {
+	versions, err := p.ListVersions()
+	if err != nil {
+		return "", "", err
+	}
+	constraint := "*"
+	if p.PreRelease {
+		constraint = ">=0.0.0-0"
+	}
+	c, _ := semver.NewConstraint(constraint)
+	if v := newestVersion(versions, c); v != "" {
+		return v, p.getZipURL(v), nil
+	}
+	d, err := p.proxyGet("/@latest")
+	if err != nil {
+		return "", "", newError(p.Cmd, ErrVersionLookup, err)
+	}
+	var info struct {
+		Version string
+	}
+	if d == nil || json.Unmarshal(d, &info) != nil || info.Version == "" {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no version of module "+p.Module))
+	}
+	v := strings.TrimPrefix(info.Version, "v")
+	return v, p.getZipURL(v), nil
+}
+
+// GetVersion returns the given version and the URL of its source, if it exists
+func (p *GoModuleProgram) GetVersion(version string) (string, string, error) {
+	v := strings.TrimPrefix(version, "v")
+	d, err := p.proxyGet("/@v/v" + v + ".info")
+	if err != nil {
+		return "", "", newError(p.Cmd, ErrVersionLookup, err)
+	}
+	if d == nil {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no version %s of module %s", version, p.Module))
+	}
+	return v, p.getZipURL(v), nil
+}
+
+// DownloadLatestVersion builds the latest version and puts it into the bindir
+func (p *GoModuleProgram) DownloadLatestVersion() (string, error) {
+	v, _, err := p.GetLatestVersion()
+	if err != nil {
+		return "", err
+	}
+	return v, p.install(v)
+}
+
+// DownloadVersion builds the given version and puts it into the bindir
+func (p *GoModuleProgram) DownloadVersion(version string) (string, error) {
+	v, _, err := p.GetVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return v, p.install(v)
+}
+
+// install runs go install into a temporary directory next to the bindir, and
+// moves the binary into place when the build has succeeded
+func (p *GoModuleProgram) install(v string) error {
+	if !IsNative() {
+		// go install refuses to put cross-compiled binaries into GOBIN
+		return newError(p.Cmd, ErrInstall, fmt.Errorf("go install can only build for %s/%s, not for %s/%s",
+			runtime.GOOS, runtime.GOARCH, OS, Arch))
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		return newError(p.Cmd, ErrInstall, errors.New("go is needed to build from source, but is not installed"))
+	}
+	pkg := p.Package
+	if pkg == "" {
+		pkg = p.Module
+	}
+	gobin, err := ioutil.TempDir(p.Path, "."+p.Cmd+".build")
+	if err != nil {
+		return newError(p.Cmd, ErrInstall, err)
+	}
+	defer os.RemoveAll(gobin)
+	cmd := exec.Command(goCmd, "install", pkg+"@v"+v)
+	cmd.Env = append(os.Environ(), "GOBIN="+gobin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newError(p.Cmd, ErrInstall, fmt.Errorf("go install %s: %s\n%s", pkg, err, out))
+	}
+	// go install names the binary after the package, without a major version suffix
+	name := path.Base(pkg)
+	if majorVersionSuffix.MatchString(name) {
+		name = path.Base(path.Dir(pkg))
+	}
+	if err := os.Rename(filepath.Join(gobin, name), p.GetFullPath()); err != nil {
+		return newError(p.Cmd, ErrInstall, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// pseudoVersion matches the pseudo-versions of untagged commits
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+
+// fakeGoProxy serves the versions of modules like a Go module proxy
+func fakeGoProxy(t *testing.T, modules map[string][]string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := strings.Index(r.URL.Path, "/@")
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		versions, ok := modules[r.URL.Path[1:i]]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		switch api := r.URL.Path[i:]; {
+		case api == "/@v/list":
+			for _, v := range versions {
+				if !pseudoVersion.MatchString(v) {
+					// Pseudo-versions are not listed
+					fmt.Fprintln(w, v)
+				}
+			}
+		case api == "/@latest":
+			fmt.Fprintf(w, `{"Version": "%s"}`, versions[len(versions)-1])
+		case strings.HasSuffix(api, ".info"):
+			for _, v := range versions {
+				if api == "/@v/"+v+".info" {
+					fmt.Fprintf(w, `{"Version": "%s"}`, v)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	t.Setenv("GOPROXY", s.URL+",direct")
+	return s
+}
+
+func TestGoModuleVersions(t *testing.T) {
+	s := fakeGoProxy(t, map[string][]string{
+		// Upper case letters are escaped in module paths
+		"github.com/!owner/!tool":   {"v1.0.0", "v1.1.0", "v2.0.0-rc1"},
+		"github.com/owner/untagged": {"v0.0.0-20190101000000-abcdefabcdef"},
+	})
+	for _, tc := range []struct {
+		module     string
+		tagName    string
+		preRelease bool
+		latest     string
+		versions   []string
+	}{
+		{"github.com/Owner/Tool", "", false, "1.1.0", []string{"1.0.0", "1.1.0", "2.0.0-rc1"}},
+		{"github.com/Owner/Tool", "", true, "2.0.0-rc1", []string{"1.0.0", "1.1.0", "2.0.0-rc1"}},
+		{"github.com/Owner/Tool", "v1.0", false, "1.0.0", []string{"1.0.0"}},
+		{"github.com/owner/untagged", "", false, "0.0.0-20190101000000-abcdefabcdef", nil},
+	} {
+		t.Run(tc.module, func(t *testing.T) {
+			p := &GoModuleProgram{Command: Command{Cmd: "tool"}, Module: tc.module, TagName: tc.tagName, PreRelease: tc.preRelease}
+			versions, err := p.ListVersions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(versions, tc.versions) {
+				t.Errorf("ListVersions() = %v, expected %v", versions, tc.versions)
+			}
+			v, u, err := p.GetLatestVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tc.latest {
+				t.Errorf("GetLatestVersion() = %s, expected %s", v, tc.latest)
+			}
+			if !strings.HasPrefix(u, s.URL+"/") {
+				t.Errorf("source of %s is not in the module proxy: %s", v, u)
+			}
+		})
+	}
+	p := &GoModuleProgram{Command: Command{Cmd: "tool"}, Module: "github.com/Owner/Tool"}
+	if v, _, err := p.GetVersion("v1.0.0"); err != nil || v != "1.0.0" {
+		t.Errorf("GetVersion(v1.0.0) = %s, %v", v, err)
+	}
+	if _, _, err := p.GetVersion("3.0.0"); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("GetVersion(3.0.0) returned %v, expected %v", err, ErrVersionNotFound)
+	}
+	p.Module = "github.com/owner/missing"
+	if _, _, err := p.GetLatestVersion(); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("GetLatestVersion() of a missing module returned %v, expected %v", err, ErrVersionNotFound)
+	}
+}
+
+func TestGoModuleOtherPlatform(t *testing.T) {
+	fakeGoProxy(t, map[string][]string{"github.com/owner/tool": {"v1.0.0"}})
+	defer func(goos string) { OS = goos }(OS)
+	OS = "plan9"
+	p := &GoModuleProgram{Command: Command{Cmd: "tool", Path: t.TempDir()}, Module: "github.com/owner/tool"}
+	if _, err := p.DownloadVersion("1.0.0"); !errors.Is(err, ErrInstall) || !strings.Contains(err.Error(), "plan9") {
+		t.Errorf("DownloadVersion(1.0.0) for another platform returned %v, expected %v", err, ErrInstall)
+	}
+}
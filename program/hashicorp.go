@@ -15,18 +15,30 @@
 package program
 
 import (
-	"fmt"
+	"bytes"
+	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/cellpointmobile/vk/file"
 	"github.com/hashicorp/go-checkpoint"
+	"github.com/spf13/viper"
+	"github.com/tidwall/gjson"
 )
 
 // HashicorpProgram is for Hashicorp programs
 type HashicorpProgram struct {
 	Command
+	Platform
+	ChecksumURL string // Optional, URL of GPG signed SHA-256 checksums. Defaults to the SHA256SUMS file of the release.
+}
+
+// GetSource returns the type of source the program is released from
+func (p *HashicorpProgram) GetSource() string {
+	return "hashicorp"
 }
 
 // GetLatestVersion returns the latest version number available
@@ -41,37 +53,101 @@ func (p *HashicorpProgram) GetLatestVersion() (string, string, error) {
 		CacheFile: cache,
 	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting version from Checkpoint: %s", err)
-		os.Exit(100)
+		return "", "", newError(cmd, ErrVersionLookup, err)
 	}
 	v := c.CurrentVersion
-	r := strings.NewReplacer(
+	return v, p.getDownloadURL(v), nil
+}
+
+// ListVersions returns all versions published on releases.hashicorp.com
+func (p *HashicorpProgram) ListVersions() ([]string, error) {
+	d, err := file.Fetch("https://releases.hashicorp.com/" + p.GetCmd() + "/index.json")
+	if err != nil {
+		return nil, newError(p.Cmd, ErrVersionLookup, err)
+	}
+	var versions []string
+	gjson.GetBytes(d, "versions").ForEach(func(k, _ gjson.Result) bool {
+		versions = append(versions, k.String())
+		return true
+	})
+	return versions, nil
+}
+
+// GetVersion returns the given version and its download URL
+func (p *HashicorpProgram) GetVersion(version string) (string, string, error) {
+	v := strings.TrimPrefix(version, "v")
+	return v, p.getDownloadURL(v), nil
+}
+
+// getDownloadURL returns the releases.hashicorp.com URL for the given version
+func (p *HashicorpProgram) getDownloadURL(v string) string {
+	r := p.replacer(
 		"{VERSION}", v,
-		"{CMD}", cmd)
-	u := "https://releases.hashicorp.com/{CMD}/{VERSION}/{CMD}_{VERSION}_linux_amd64.zip"
-	url := r.Replace(u)
-	return v, url, err
+		"{CMD}", p.GetCmd())
+	u := "https://releases.hashicorp.com/{CMD}/{VERSION}/{CMD}_{VERSION}_{OS}_{ARCH}.zip"
+	return r.Replace(u)
+}
+
+// getHashicorpKeyring returns the configured GPG keyring, or the bundled Hashicorp key
+func getHashicorpKeyring() (io.Reader, error) {
+	if k := viper.GetString("hashicorp-keyring"); k != "" {
+		d, err := ioutil.ReadFile(os.ExpandEnv(k))
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(d), nil
+	}
+	return strings.NewReader(hashicorpPublicKey), nil
+}
+
+// getChecksumURL returns the URL of the SHA-256 checksums for the given version
+func (p *HashicorpProgram) getChecksumURL(v string) string {
+	u := p.ChecksumURL
+	if u == "" {
+		u = "https://releases.hashicorp.com/{CMD}/{VERSION}/{CMD}_{VERSION}_SHA256SUMS"
+	}
+	r := p.replacer(
+		"{VERSION}", v,
+		"{CMD}", p.GetCmd())
+	return r.Replace(u)
 }
 
 // DownloadLatestVersion downloads and extracts the latest version
-func (p *HashicorpProgram) DownloadLatestVersion() string {
-	f := filepath.Join(p.Path, p.Cmd)
+func (p *HashicorpProgram) DownloadLatestVersion() (string, error) {
 	v, url, err := p.GetLatestVersion()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Can't get latest version.")
-		os.Exit(10)
+		return "", err
+	}
+	return v, p.download(v, url)
+}
+
+// DownloadVersion downloads and extracts the given version
+func (p *HashicorpProgram) DownloadVersion(version string) (string, error) {
+	v, url, err := p.GetVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return v, p.download(v, url)
+}
+
+func (p *HashicorpProgram) download(v string, url string) error {
+	f := filepath.Join(p.Path, p.Cmd)
+	keyring, err := getHashicorpKeyring()
+	if err != nil {
+		return newError(p.Cmd, ErrChecksum, err)
+	}
+	cu := p.getChecksumURL(v)
+	sum, err := file.FetchSignedChecksum(cu, cu+".sig", keyring, path.Base(url))
+	if err != nil {
+		return newError(p.Cmd, ErrChecksum, err)
 	}
 	err = file.ExtractFromZip(
 		url,
 		p.Cmd,
-		f)
+		f,
+		sum)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error extracting file from zip: %s", err)
-		os.Exit(90)
-	}
-	if err = os.Chmod(f, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Error setting chmod for downloaded file: %s", err)
-		os.Exit(80)
+		return newError(p.Cmd, ErrExtract, err)
 	}
-	return v
+	return nil
 }
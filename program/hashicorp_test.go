@@ -0,0 +1,83 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/cellpointmobile/vk/file"
+	"github.com/spf13/viper"
+)
+
+func TestHashicorpKeyIsValid(t *testing.T) {
+	keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(hashicorpPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].PrimaryKey.KeyIdString() != "34365D9472D7468F" {
+		t.Fatalf("bundled keyring is not the Hashicorp key")
+	}
+	if _, ok := keys[0].SigningKey(time.Now()); !ok {
+		t.Error("Hashicorp key has no valid signing key, it must be renewed")
+	}
+}
+
+// TestHashicorpSignature verifies the checksums of a release of the Terraform
+// null provider, signed by Hashicorp in April 2021 with a subkey which expired
+// in April 2022. testdata/hashicorp-2021.asc is the Hashicorp key as it was
+// published then.
+func TestHashicorpSignature(t *testing.T) {
+	s := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer s.Close()
+	defer func(now func() time.Time) { file.Now = now }(file.Now)
+	keyring := viper.GetString("hashicorp-keyring")
+	defer viper.Set("hashicorp-keyring", keyring)
+	checksums := s.URL + "/terraform-provider-null_3.1.0_SHA256SUMS"
+	for _, tc := range []struct {
+		name    string
+		keyring string
+		now     time.Time
+		valid   bool
+	}{
+		{"signed", "testdata/hashicorp-2021.asc", time.Date(2021, 4, 23, 0, 0, 0, 0, time.UTC), true},
+		{"signed by expired subkey", "testdata/hashicorp-2021.asc", time.Date(2022, 4, 21, 0, 0, 0, 0, time.UTC), false},
+		{"signed by expired key", "testdata/hashicorp-2021.asc", time.Now(), false},
+		{"signed by expired subkey of bundled key", "", time.Now(), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file.Now = func() time.Time { return tc.now }
+			viper.Set("hashicorp-keyring", tc.keyring)
+			keyring, err := getHashicorpKeyring()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum, err := file.FetchSignedChecksum(checksums, checksums+".sig", keyring, "terraform-provider-null_3.1.0_linux_amd64.zip")
+			switch {
+			case tc.valid && err != nil:
+				t.Fatal(err)
+			case tc.valid && sum != "53e30545ff8926a8e30ad30648991ca8b93b6fa496272cd23b26763c8ee84515":
+				t.Errorf("checksum of linux_amd64 is %s", sum)
+			case !tc.valid && (!errors.Is(err, file.ErrInvalidSignature) || !strings.Contains(err.Error(), "key expired")):
+				t.Errorf("FetchSignedChecksum() returned %v, expected an expired key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/file"
+	"github.com/tidwall/gjson"
+)
+
+// HTTPProgram is a Program downloaded from a plain HTTP server, which
+// publishes its latest version or a list of versions at a URL
+type HTTPProgram struct {
+	Command
+	Platform
+	LatestVersionURL    string // Optional, URL returning the latest version. Ex: https://dl.k8s.io/release/stable.txt
+	VersionsURL         string // Optional, URL listing all versions, ex: a directory listing. Used for the latest version if LatestVersionURL is empty.
+	RemoteVersionRegexp string // Optional, regexp finding versions in the responses. The first submatch is used, if any.
+	RemoteVersionPath   string // Optional, gjson path finding versions in JSON responses. The keys of objects are used as versions. Ex: versions.#.version
+	DownloadURL         string // Ex: https://dl.k8s.io/release/v{VERSION}/bin/{OS}/{ARCH}/kubectl
+	ChecksumURL         string // Optional, URL of SHA-256 checksums. Ex: https://dl.k8s.io/release/v{VERSION}/bin/{OS}/{ARCH}/kubectl.sha256
+}
+
+// HTTPDirectDownloadProgram downloads a file directly
+type HTTPDirectDownloadProgram struct {
+	HTTPProgram
+}
+
+// HTTPDownloadUntarFileProgram downloads a tarball and extracts files from it
+type HTTPDownloadUntarFileProgram struct {
+	HTTPProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// HTTPDownloadUnzipFileProgram downloads a zip-file and extracts files from it
+type HTTPDownloadUnzipFileProgram struct {
+	HTTPProgram
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// getRemoteVersions downloads a URL and returns the versions found in the response
+func (p *HTTPProgram) getRemoteVersions(u string) ([]string, error) {
+	d, err := file.Fetch(p.replacer().Replace(u))
+	if err != nil {
+		return nil, newError(p.Cmd, ErrVersionLookup, err)
+	}
+	var versions []string
+	switch {
+	case p.RemoteVersionPath != "":
+		r := gjson.GetBytes(d, p.RemoteVersionPath)
+		switch {
+		case r.IsArray():
+			for _, v := range r.Array() {
+				versions = append(versions, v.String())
+			}
+		case r.IsObject():
+			// Objects are indexed by version, like the index.json of releases.hashicorp.com
+			r.ForEach(func(k, _ gjson.Result) bool {
+				versions = append(versions, k.String())
+				return true
+			})
+		case r.Exists():
+			versions = append(versions, r.String())
+		}
+	case p.RemoteVersionRegexp != "":
+		vr, err := regexp.Compile(p.RemoteVersionRegexp)
+		if err != nil {
+			return nil, newError(p.Cmd, ErrVersionParse, err)
+		}
+		for _, match := range vr.FindAllStringSubmatch(string(d), -1) {
+			versions = append(versions, match[len(match)-1])
+		}
+	default:
+		versions = strings.Fields(string(d))
+	}
+	for i, v := range versions {
+		versions[i] = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	}
+	return versions, nil
+}
+
+// GetSource returns the type of source the program is released from
+func (p *HTTPProgram) GetSource() string {
+	return "http"
+}
+
+// GetLatestVersion returns the latest version available
+func (p *HTTPProgram) GetLatestVersion() (string, string, error) {
+	if p.LatestVersionURL == "" {
+		if p.VersionsURL == "" {
+			return "", "", newError(p.Cmd, ErrVersionLookup, errors.New("no LatestVersionURL or VersionsURL defined"))
+		}
+		versions, err := p.ListVersions()
+		if err != nil {
+			return "", "", err
+		}
+		c, _ := semver.NewConstraint("*")
+		v := newestVersion(versions, c)
+		if v == "" {
+			return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no version found at "+p.VersionsURL))
+		}
+		return p.GetVersion(v)
+	}
+	versions, err := p.getRemoteVersions(p.LatestVersionURL)
+	if err != nil {
+		return "", "", err
+	}
+	if len(versions) == 0 {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no version found at "+p.LatestVersionURL))
+	}
+	return p.GetVersion(versions[0])
+}
+
+// ListVersions returns the versions listed at VersionsURL, or only the latest
+// version if the program has no VersionsURL
+func (p *HTTPProgram) ListVersions() ([]string, error) {
+	if p.VersionsURL == "" {
+		v, _, err := p.GetLatestVersion()
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, nil
+	}
+	return p.getRemoteVersions(p.VersionsURL)
+}
+
+// GetVersion returns the given version and its download URL
+func (p *HTTPProgram) GetVersion(version string) (string, string, error) {
+	v := strings.TrimPrefix(version, "v")
+	return v, p.replacer("{VERSION}", v).Replace(p.DownloadURL), nil
+}
+
+// getChecksum returns the published SHA-256 checksum of the download URL.
+// Returns an empty string if the program has no checksums defined.
+func (p *HTTPProgram) getChecksum(v string, u string) (string, error) {
+	return releaseChecksum(p.Cmd, p.replacer("{VERSION}", v), p.ChecksumURL, "", u)
+}
+
+// DownloadLatestVersion downloads the latest version and puts it into the bindir
+func (p *HTTPDirectDownloadProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads the given version and puts it into the bindir
+func (p *HTTPDirectDownloadProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *HTTPDirectDownloadProgram) download(v string, u string) error {
+	return downloadFile(p, v, u)
+}
+
+// DownloadLatestVersion downloads and untars a file to the bindir
+func (p *HTTPDownloadUntarFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and untars the given version to the bindir
+func (p *HTTPDownloadUntarFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *HTTPDownloadUntarFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromTar, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
+
+// DownloadLatestVersion downloads and unzips a file to the bindir
+func (p *HTTPDownloadUnzipFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and unzips the given version to the bindir
+func (p *HTTPDownloadUnzipFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *HTTPDownloadUnzipFileProgram) download(v string, u string) error {
+	return extractFiles(p, v, u, file.ExtractFilesFromZip, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
@@ -0,0 +1,163 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testTarball returns a gzipped tarball of the files
+func testTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestHTTPVersions(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable.txt":
+			fmt.Fprint(w, "v1.2.0\n")
+		case "/releases/":
+			fmt.Fprint(w, `<a href="tool-1.0.0/">tool-1.0.0/</a><a href="tool-1.2.0/">tool-1.2.0/</a><a href="tool-1.10.0-rc1/">`)
+		case "/index.json":
+			fmt.Fprint(w, `{"versions": {"1.0.0": {}, "1.1.0": {}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	for _, tc := range []struct {
+		name     string
+		program  HTTPProgram
+		latest   string
+		versions []string
+	}{
+		{
+			name:     "LatestVersionURL",
+			program:  HTTPProgram{LatestVersionURL: s.URL + "/stable.txt"},
+			latest:   "1.2.0",
+			versions: []string{"1.2.0"},
+		},
+		{
+			name:     "RemoteVersionRegexp",
+			program:  HTTPProgram{VersionsURL: s.URL + "/releases/", RemoteVersionRegexp: `href="tool-([^/"]+)/"`},
+			latest:   "1.2.0",
+			versions: []string{"1.0.0", "1.10.0-rc1", "1.2.0"},
+		},
+		{
+			name:     "RemoteVersionPath",
+			program:  HTTPProgram{VersionsURL: s.URL + "/index.json", RemoteVersionPath: "versions"},
+			latest:   "1.1.0",
+			versions: []string{"1.0.0", "1.1.0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.program
+			p.Cmd = "tool"
+			p.DownloadURL = "https://example.com/v{VERSION}/{OS}/{ARCH}/tool"
+			v, u, err := p.GetLatestVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tc.latest || u != "https://example.com/v"+tc.latest+"/"+OS+"/"+Arch+"/tool" {
+				t.Errorf("GetLatestVersion() = %s, %s", v, u)
+			}
+			versions, err := p.ListVersions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(versions)
+			if !reflect.DeepEqual(versions, tc.versions) {
+				t.Errorf("ListVersions() = %v, expected %v", versions, tc.versions)
+			}
+		})
+	}
+}
+
+// TestHTTPUntarFile extracts only Filename, verified by the checksum of the tarball
+func TestHTTPUntarFile(t *testing.T) {
+	tarball := testTarball(t, map[string]string{
+		"tool-1.0.0/tool":       testContent("1.0.0"),
+		"tool-1.0.0/README.md":  "readme",
+		"tool-1.0.0/completion": "complete",
+	})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.0.0/tool-1.0.0.tar.gz":
+			w.Write(tarball)
+		case "/1.0.0/SHA256SUMS":
+			fmt.Fprintf(w, "%s  tool-1.0.0.tar.gz\n", sha256Hex(string(tarball)))
+		case "/2.0.0/tool-2.0.0.tar.gz":
+			w.Write(tarball)
+		case "/2.0.0/SHA256SUMS":
+			fmt.Fprintf(w, "%s  tool-2.0.0.tar.gz\n", sha256Hex("other"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+	p := &HTTPDownloadUntarFileProgram{
+		HTTPProgram: HTTPProgram{
+			Command:     Command{Cmd: "tool", Path: testHome(t)},
+			DownloadURL: s.URL + "/{VERSION}/tool-{VERSION}.tar.gz",
+			ChecksumURL: s.URL + "/{VERSION}/SHA256SUMS",
+		},
+		Filename: "tool-{VERSION}/tool",
+	}
+	if _, err := p.DownloadVersion("1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(p.GetFullPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d) != testContent("1.0.0") {
+		t.Errorf("extracted %q", d)
+	}
+	if _, err = ioutil.ReadFile(filepath.Join(p.Path, "README.md")); err == nil {
+		t.Error("files besides Filename are extracted")
+	}
+	if _, err = p.DownloadVersion("2.0.0"); !errors.Is(err, ErrExtract) {
+		t.Errorf("DownloadVersion(2.0.0) with a mismatching checksum returned %v, expected %v", err, ErrExtract)
+	}
+}
@@ -18,8 +18,14 @@ package program
 type IProgram interface {
 	GetCmd() string
 	GetFullPath() string
-	GetLocalVersion() string
+	GetExecutable() string
+	SetPath(path string)
+	GetSource() string
+	GetLocalVersion() (string, error)
 	GetLatestVersion() (string, string, error)
+	GetVersion(version string) (string, string, error)
+	ListVersions() ([]string, error)
 	IsInstalled() bool
-	DownloadLatestVersion() string
+	DownloadLatestVersion() (string, error)
+	DownloadVersion(version string) (string, error)
 }
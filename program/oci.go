@@ -0,0 +1,397 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/file"
+)
+
+// Media types of OCI and Docker manifests and indexes
+const (
+	ociIndexMediaType       = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerListMediaType     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// ociTitleAnnotation is the annotation ORAS sets to the filename of a layer
+const ociTitleAnnotation = "org.opencontainers.image.title"
+
+// OCIProgram is a Program published as an OCI artifact in a container registry, ex: with ORAS
+type OCIProgram struct {
+	Command
+	Platform
+	Registry   string // Host of the registry. Ex: ghcr.io. Use http://host:port for registries without TLS
+	Repository string // Ex: cellpointmobile/tool
+	TagName    string // Optional, prefix of the tags of the program. Ex: tool will find tool-v1.2.3 or tool-1.2.3
+	PreRelease bool   // Accept prereleases, which are versions with a semver prerelease part, ex: 1.2.0-rc1. Defaults to false
+	LayerTitle string // Optional, title annotation of the layer to download. Ex: tool_{OS}_{ARCH}
+	MediaType  string // Optional, media type of the layer to download. Ex: application/vnd.cellpointmobile.tool.binary
+
+	authorization string
+}
+
+// ociDescriptor describes a manifest or layer in a registry
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+// ociManifest is an image manifest or an index of manifests for multiple platforms
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+var ociChallengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ociCandidateTags returns the tags a version may be tagged with. Tags can't
+// contain / or +, so the TagName prefix is separated by - and the + of semver
+// build metadata is replaced by _, like Helm does.
+func ociCandidateTags(tagName string, version string) []string {
+	version = strings.Replace(strings.TrimPrefix(version, "v"), "+", "_", -1)
+	if tagName == "" {
+		return []string{"v" + version, version}
+	}
+	return []string{tagName + "-v" + version, tagName + "-" + version}
+}
+
+// ociVersionFromTag returns the version of a tag, or false if it is not a tag of the TagName prefix
+func ociVersionFromTag(tagName string, tag string) (string, bool) {
+	if tagName != "" {
+		if !strings.HasPrefix(tag, tagName+"-") {
+			return "", false
+		}
+		tag = strings.TrimPrefix(tag, tagName+"-")
+	}
+	return strings.Replace(strings.TrimPrefix(tag, "v"), "_", "+", -1), true
+}
+
+// getRepositoryURL returns the URL of the repository in the registry API
+func (p *OCIProgram) getRepositoryURL() string {
+	r := strings.TrimSuffix(p.Registry, "/")
+	if !strings.Contains(r, "://") {
+		r = "https://" + r
+	}
+	return r + "/v2/" + p.Repository
+}
+
+// getCredentials returns the authorization of the registry in the Docker config, if any
+func (p *OCIProgram) getCredentials() string {
+	d, err := ioutil.ReadFile(os.ExpandEnv("$HOME/.docker/config.json"))
+	if err != nil {
+		return ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(d, &config) != nil {
+		return ""
+	}
+	host := p.Registry
+	if u, err := url.Parse(p.Registry); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	for _, k := range []string{host, "https://" + host, "http://" + host} {
+		if a, ok := config.Auths[k]; ok && a.Auth != "" {
+			return a.Auth
+		}
+	}
+	return ""
+}
+
+// authorize gets an authorization for the repository, as requested by the challenge of the registry
+func (p *OCIProgram) authorize(challenge string) error {
+	credentials := p.getCredentials()
+	if strings.HasPrefix(challenge, "Basic") {
+		if credentials == "" {
+			return errors.New("registry requires credentials, please log in with docker login")
+		}
+		p.authorization = "Basic " + credentials
+		p.setAuthorizer()
+		return nil
+	}
+	params := make(map[string]string)
+	for _, m := range ociChallengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	q := url.Values{}
+	q.Set("service", params["service"])
+	q.Set("scope", "repository:"+p.Repository+":pull")
+	header := make(http.Header)
+	if credentials != "" {
+		header.Set("Authorization", "Basic "+credentials)
+	}
+	resp, err := file.Get(params["realm"]+"?"+q.Encode(), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("can't get registry token: %s", resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return err
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	p.authorization = "Bearer " + t.Token
+	p.setAuthorizer()
+	return nil
+}
+
+// setAuthorizer makes all requests of the repository, like downloads of blobs,
+// use the current authorization
+func (p *OCIProgram) setAuthorizer() {
+	file.SetAuthorizer(p.getRepositoryURL()+"/", func(r *http.Request) error {
+		r.Header.Set("Authorization", p.authorization)
+		return nil
+	})
+}
+
+// registryGet gets a URL of the registry API, authorizing if the registry requires it
+func (p *OCIProgram) registryGet(u string, accept ...string) (*http.Response, error) {
+	header := make(http.Header)
+	for _, a := range accept {
+		header.Add("Accept", a)
+	}
+	for attempt := 0; ; attempt++ {
+		if p.authorization != "" {
+			header.Set("Authorization", p.authorization)
+		}
+		resp, err := file.Get(u, header)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if err = p.authorize(resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getManifest returns the manifest of a tag. Returns nil if the tag does not exist.
+func (p *OCIProgram) getManifest(reference string) (*ociManifest, error) {
+	resp, err := p.registryGet(p.getRepositoryURL()+"/manifests/"+reference,
+		ociIndexMediaType, ociManifestMediaType, dockerListMediaType, dockerManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't get manifest %s: %s", reference, resp.Status)
+	}
+	var m ociManifest
+	if err = json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// findLayer returns the layer to download from a manifest. Indexes are
+// resolved to the manifest of the platform first.
+func (p *OCIProgram) findLayer(m *ociManifest, v string) (*ociDescriptor, error) {
+	if len(m.Manifests) > 0 {
+		var digest string
+		for _, d := range m.Manifests {
+			if d.Platform.OS == p.GetOS() && d.Platform.Architecture == p.GetArch() {
+				digest = d.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("no manifest for platform %s/%s", p.GetOS(), p.GetArch())
+		}
+		pm, err := p.getManifest(digest)
+		if err != nil {
+			return nil, err
+		}
+		if pm == nil {
+			return nil, fmt.Errorf("manifest %s not found", digest)
+		}
+		m = pm
+	}
+	title := p.replacer("{VERSION}", v).Replace(p.LayerTitle)
+	var layers []ociDescriptor
+	for _, l := range m.Layers {
+		if title != "" && l.Annotations[ociTitleAnnotation] != title {
+			continue
+		}
+		if p.MediaType != "" && l.MediaType != p.MediaType {
+			continue
+		}
+		layers = append(layers, l)
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("%d layers match title '%s' and media type '%s', expected 1", len(layers), title, p.MediaType)
+	}
+	return &layers[0], nil
+}
+
+// getVersionURL returns the download URL of the layer of a tag
+func (p *OCIProgram) getVersionURL(tag string) (string, bool, error) {
+	m, err := p.getManifest(tag)
+	if err != nil {
+		return "", false, newError(p.Cmd, ErrVersionLookup, err)
+	}
+	if m == nil {
+		return "", false, nil
+	}
+	v, _ := ociVersionFromTag(p.TagName, tag)
+	l, err := p.findLayer(m, v)
+	if err != nil {
+		return "", true, newError(p.Cmd, ErrAssetNotFound, err)
+	}
+	return p.getRepositoryURL() + "/blobs/" + l.Digest, true, nil
+}
+
+// GetSource returns the type of source the program is released from
+func (p *OCIProgram) GetSource() string {
+	return "oci"
+}
+
+// ListVersions returns the versions of all tags of the repository matching TagName
+func (p *OCIProgram) ListVersions() ([]string, error) {
+	var versions []string
+	u := p.getRepositoryURL() + "/tags/list?n=1000"
+	for u != "" {
+		resp, err := p.registryGet(u)
+		if err != nil {
+			return nil, newError(p.Cmd, ErrVersionLookup, err)
+		}
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&tags)
+		} else {
+			err = fmt.Errorf("can't list tags: %s", resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, newError(p.Cmd, ErrVersionLookup, err)
+		}
+		for _, t := range tags.Tags {
+			if v, ok := ociVersionFromTag(p.TagName, t); ok {
+				versions = append(versions, v)
+			}
+		}
+		u = ""
+		// The next page is linked as: </v2/<name>/tags/list?n=1000&last=x>; rel="next"
+		if l := resp.Header.Get("Link"); strings.Contains(l, `rel="next"`) {
+			next, err := resp.Request.URL.Parse(strings.Trim(strings.SplitN(l, ";", 2)[0], "<> "))
+			if err == nil {
+				u = next.String()
+			}
+		}
+	}
+	return versions, nil
+}
+
+// GetLatestVersion returns the highest version tagged in the repository
+func (p *OCIProgram) GetLatestVersion() (string, string, error) {
+	versions, err := p.ListVersions()
+	if err != nil {
+		return "", "", err
+	}
+	constraint := "*"
+	if p.PreRelease {
+		constraint = ">=0.0.0-0"
+	}
+	c, _ := semver.NewConstraint(constraint)
+	v := newestVersion(versions, c)
+	if v == "" {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no tag with a semver version"))
+	}
+	return p.GetVersion(v)
+}
+
+// GetVersion returns the given version and its download URL, if it has been tagged
+func (p *OCIProgram) GetVersion(version string) (string, string, error) {
+	for _, tag := range ociCandidateTags(p.TagName, version) {
+		u, ok, err := p.getVersionURL(tag)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			v, _ := ociVersionFromTag(p.TagName, tag)
+			return v, u, nil
+		}
+	}
+	return "", "", newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no tag of version %s", version))
+}
+
+// DownloadLatestVersion downloads the layer of the latest version and puts it into the bindir
+func (p *OCIProgram) DownloadLatestVersion() (string, error) {
+	v, url, err := p.GetLatestVersion()
+	if err != nil {
+		return "", err
+	}
+	return v, p.download(url)
+}
+
+// DownloadVersion downloads the layer of the given version and puts it into the bindir
+func (p *OCIProgram) DownloadVersion(version string) (string, error) {
+	v, url, err := p.GetVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return v, p.download(url)
+}
+
+func (p *OCIProgram) download(url string) error {
+	f := filepath.Join(p.Path, p.Cmd)
+	// Blobs are addressed by their digest, which is verified after downloading
+	var sum string
+	if digest := url[strings.LastIndex(url, "/")+1:]; strings.HasPrefix(digest, "sha256:") {
+		sum = strings.TrimPrefix(digest, "sha256:")
+	}
+	err := file.Download(url, f, sum)
+	if err != nil {
+		return newError(p.Cmd, ErrDownload, err)
+	}
+	return nil
+}
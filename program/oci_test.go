@@ -0,0 +1,175 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ociTagRegexp matches valid tags, as defined by the OCI distribution spec
+var ociTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+
+// fakeRegistry serves the repository tool like the registry:2 image, with
+// token authentication. Each tag has an index of a manifest per platform.
+func fakeRegistry(t *testing.T, tags []string) *httptest.Server {
+	blobs := make(map[string]string)
+	manifests := make(map[string]interface{})
+	for _, tag := range tags {
+		v, _ := ociVersionFromTag("tool", tag)
+		blob := testContent(v)
+		blobDigest := "sha256:" + sha256Hex(blob)
+		blobs[blobDigest] = blob
+		manifest := map[string]interface{}{
+			"mediaType": ociManifestMediaType,
+			"layers": []map[string]interface{}{
+				{"mediaType": "application/vnd.test.readme", "digest": "sha256:0", "annotations": map[string]string{ociTitleAnnotation: "README.md"}},
+				{"mediaType": "application/vnd.test.binary", "digest": blobDigest, "annotations": map[string]string{ociTitleAnnotation: "tool_" + OS + "_" + Arch}},
+			},
+		}
+		d, _ := json.Marshal(manifest)
+		manifestDigest := "sha256:" + sha256Hex(string(d))
+		manifests[manifestDigest] = manifest
+		manifests[tag] = map[string]interface{}{
+			"mediaType": ociIndexMediaType,
+			"manifests": []map[string]interface{}{
+				{"mediaType": ociManifestMediaType, "digest": "sha256:1", "platform": map[string]string{"os": "plan9", "architecture": Arch}},
+				{"mediaType": ociManifestMediaType, "digest": manifestDigest, "platform": map[string]string{"os": OS, "architecture": Arch}},
+			},
+		}
+	}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("service") != "registry.test" || r.URL.Query().Get("scope") != "repository:tool:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"token": "anonymous"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+s.URL+`/token",service="registry.test",scope="repository:tool:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/tool/tags/list":
+			// Pages of two tags
+			sorted := append([]string(nil), tags...)
+			sort.Strings(sorted)
+			last := r.URL.Query().Get("last")
+			i := sort.SearchStrings(sorted, last)
+			if last != "" {
+				i++
+			}
+			page := sorted[i:]
+			if len(page) > 2 {
+				page = page[:2]
+				w.Header().Set("Link", fmt.Sprintf(`</v2/tool/tags/list?n=2&last=%s>; rel="next"`, page[1]))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "tool", "tags": page})
+		case strings.HasPrefix(r.URL.Path, "/v2/tool/manifests/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/v2/tool/manifests/")
+			if !strings.HasPrefix(reference, "sha256:") && !ociTagRegexp.MatchString(reference) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors": [{"code": "TAG_INVALID"}]}`)
+				return
+			}
+			m, ok := manifests[reference]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(m)
+		case strings.HasPrefix(r.URL.Path, "/v2/tool/blobs/"):
+			b, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/tool/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newOCIProgram(t *testing.T, s *httptest.Server) *OCIProgram {
+	return &OCIProgram{
+		Command:    Command{Cmd: "tool", Path: testHome(t)},
+		Registry:   s.URL,
+		Repository: "tool",
+		TagName:    "tool",
+		LayerTitle: "tool_{OS}_{ARCH}",
+	}
+}
+
+func TestOCIVersions(t *testing.T) {
+	p := newOCIProgram(t, fakeRegistry(t, []string{"tool-v1.0.0", "tool-v1.0.1_build.5", "tool-1.1.0", "tool-v1.2.0-rc1", "other-v2.0.0", "v3.0.0"}))
+	versions, err := p.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(versions)
+	if expected := []string{"1.0.0", "1.0.1+build.5", "1.1.0", "1.2.0-rc1"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("ListVersions() = %v, expected %v", versions, expected)
+	}
+	v, u, err := p.GetLatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.1.0" || u != p.Registry+"/v2/tool/blobs/sha256:"+sha256Hex(testContent("1.1.0")) {
+		t.Errorf("GetLatestVersion() = %s, %s", v, u)
+	}
+	if v, _, err = p.GetVersion("1.0.1+build.5"); err != nil || v != "1.0.1+build.5" {
+		t.Errorf("GetVersion(1.0.1+build.5) = %s, %v", v, err)
+	}
+}
+
+func TestOCICandidateTags(t *testing.T) {
+	for _, tc := range []struct {
+		tagName string
+		version string
+		tags    []string
+	}{
+		{"", "1.2.3", []string{"v1.2.3", "1.2.3"}},
+		{"", "v1.2.3", []string{"v1.2.3", "1.2.3"}},
+		{"tool", "1.2.3", []string{"tool-v1.2.3", "tool-1.2.3"}},
+		{"tool", "1.2.3+build.1", []string{"tool-v1.2.3_build.1", "tool-1.2.3_build.1"}},
+	} {
+		tags := ociCandidateTags(tc.tagName, tc.version)
+		if !reflect.DeepEqual(tags, tc.tags) {
+			t.Errorf("ociCandidateTags(%q, %q) = %v, expected %v", tc.tagName, tc.version, tags, tc.tags)
+		}
+		for _, tag := range tags {
+			if !ociTagRegexp.MatchString(tag) {
+				t.Errorf("%s is not a valid OCI tag", tag)
+			}
+			if v, ok := ociVersionFromTag(tc.tagName, tag); !ok || v != strings.TrimPrefix(tc.version, "v") {
+				t.Errorf("ociVersionFromTag(%q, %q) = %s, %t", tc.tagName, tag, v, ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"runtime"
+	"strings"
+)
+
+// OS variable for os flag. Defaults to the OS vk is running on.
+var OS = runtime.GOOS
+
+// Arch variable for arch flag. Defaults to the architecture vk is running on.
+var Arch = runtime.GOARCH
+
+// IsNative returns true if tools are installed for the platform vk is running on
+func IsNative() bool {
+	return OS == runtime.GOOS && Arch == runtime.GOARCH
+}
+
+// Platform maps OS and architecture to the names used in release assets.
+// Ex: an ArchMap of {"amd64": "x86_64"} replaces {ARCH} with x86_64 on amd64.
+type Platform struct {
+	OSMap   map[string]string
+	ArchMap map[string]string
+}
+
+// GetOS returns the target OS as named by the program
+func (p *Platform) GetOS() string {
+	if os, ok := p.OSMap[OS]; ok {
+		return os
+	}
+	return OS
+}
+
+// GetArch returns the target architecture as named by the program
+func (p *Platform) GetArch() string {
+	if arch, ok := p.ArchMap[Arch]; ok {
+		return arch
+	}
+	return Arch
+}
+
+// replacer returns a Replacer for the {OS} and {ARCH} placeholders and the given pairs
+func (p *Platform) replacer(oldnew ...string) *strings.Replacer {
+	return strings.NewReplacer(append(oldnew,
+		"{OS}", p.GetOS(),
+		"{ARCH}", p.GetArch())...)
+}
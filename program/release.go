@@ -0,0 +1,143 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cellpointmobile/vk/file"
+)
+
+// releaseSource is a program whose versions are published at download URLs
+type releaseSource interface {
+	GetCmd() string
+	GetFullPath() string
+	GetLatestVersion() (string, string, error)
+	GetVersion(version string) (string, string, error)
+	getChecksum(v string, u string) (string, error)
+}
+
+// downloadLatest downloads the latest version of the program with download
+func downloadLatest(p releaseSource, download func(v string, u string) error) (string, error) {
+	v, u, err := p.GetLatestVersion()
+	if err != nil {
+		return "", err
+	}
+	return v, download(v, u)
+}
+
+// downloadVersion downloads the given version of the program with download
+func downloadVersion(p releaseSource, version string, download func(v string, u string) error) (string, error) {
+	v, u, err := p.GetVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return v, download(v, u)
+}
+
+// downloadFile downloads the file at u into the bindir, verified by its published checksum
+func downloadFile(p releaseSource, v string, u string) error {
+	sum, err := p.getChecksum(v, u)
+	if err != nil {
+		return err
+	}
+	if err = file.Download(u, p.GetFullPath(), sum); err != nil {
+		return newError(p.GetCmd(), ErrDownload, err)
+	}
+	return nil
+}
+
+// extractFiles downloads the archive at u, verified by its published
+// checksum, and extracts the targets from it
+func extractFiles(p releaseSource, v string, u string, extract func(string, []file.Target, string) error, targets []file.Target) error {
+	sum, err := p.getChecksum(v, u)
+	if err != nil {
+		return err
+	}
+	if err = extract(u, targets, sum); err != nil {
+		return newError(p.GetCmd(), ErrExtract, err)
+	}
+	return nil
+}
+
+// releaseChecksum returns the published SHA-256 checksum of the download URL
+// u, from checksumURL or else from the checksumAsset released besides u.
+// Returns an empty string if neither is defined.
+func releaseChecksum(cmd string, rx *strings.Replacer, checksumURL string, checksumAsset string, u string) (string, error) {
+	switch {
+	case checksumURL != "":
+		return fetchChecksum(cmd, rx.Replace(checksumURL), u)
+	case checksumAsset != "":
+		// Release assets are downloaded from the same path
+		return fetchChecksum(cmd, u[:strings.LastIndex(u, "/")+1]+rx.Replace(checksumAsset), u)
+	}
+	return "", nil
+}
+
+// fetchChecksum returns the checksum of the download URL u from the checksum
+// file at cu. Returns an empty string if cu is empty.
+func fetchChecksum(cmd string, cu string, u string) (string, error) {
+	if cu == "" {
+		return "", nil
+	}
+	sum, err := file.FetchChecksum(cu, path.Base(u))
+	if err != nil {
+		return "", newError(cmd, ErrChecksum, err)
+	}
+	return sum, nil
+}
+
+// asset is a file attached to a release
+type asset struct {
+	Name string
+	URL  string
+}
+
+// findAsset returns the asset with the given name
+func findAsset(assets []asset, name string) (asset, error) {
+	for _, a := range assets {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return asset{}, fmt.Errorf("no asset named %s", name)
+}
+
+// versionFromTag returns the version number of a release based on its tag and the TagName prefix
+func versionFromTag(tagName string, tag string) string {
+	if tagName == "" {
+		// No TagName prefix specified, just trim away any prefixed "v"
+		return strings.TrimPrefix(tag, "v")
+	}
+	// TagName prefix specified, first trim away TagName, then any remaining prefixed "v"
+	v := strings.TrimPrefix(tag, tagName)
+	return strings.TrimPrefix(v, "/")
+}
+
+// candidateTags returns the tags a release of the given version may be tagged with
+func candidateTags(tagName string, version string) []string {
+	version = strings.TrimPrefix(version, "v")
+	if tagName == "" {
+		return []string{"v" + version, version}
+	}
+	return []string{
+		tagName + "/v" + version,
+		tagName + "/" + version,
+		tagName + "v" + version,
+		tagName + version,
+	}
+}
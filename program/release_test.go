@@ -0,0 +1,147 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeHTTPReleases serves tarballs of versions 1.0.0 and 1.1.0 of tool, with
+// checksums, and the latest version in stable.txt
+func fakeHTTPReleases(t *testing.T) *httptest.Server {
+	files := map[string]string{"/stable.txt": "v1.1.0\n"}
+	for _, v := range []string{"1.0.0", "1.1.0"} {
+		tarball := string(testTarball(t, map[string]string{"tool-" + v + "/tool": testContent(v)}))
+		files["/"+v+"/tool-"+v+".tar.gz"] = tarball
+		files["/"+v+"/SHA256SUMS"] = sha256Hex(tarball) + "  tool-" + v + ".tar.gz\n"
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, f)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// TestDownload downloads versions 1.0.0 and 1.1.0, the latest, of tool from each source
+func TestDownload(t *testing.T) {
+	for _, tc := range []struct {
+		source  string
+		program func(t *testing.T) IProgram
+		missing error // Error of downloading a version which is not released
+	}{
+		{
+			source: "gitlab",
+			program: func(t *testing.T) IProgram {
+				return newGitlabProgram(t, fakeGitlab(t, []string{"v1.1.0", "v1.0.0"}, false))
+			},
+			missing: ErrVersionNotFound,
+		},
+		{
+			source: "gitea",
+			program: func(t *testing.T) IProgram {
+				return newGiteaProgram(t, fakeGitea(t, []giteaTestRelease{{tag: "v1.1.0"}, {tag: "v1.0.0"}}))
+			},
+			missing: ErrVersionNotFound,
+		},
+		{
+			source: "oci",
+			program: func(t *testing.T) IProgram {
+				return newOCIProgram(t, fakeRegistry(t, []string{"tool-v1.0.0", "tool-v1.1.0"}))
+			},
+			missing: ErrVersionNotFound,
+		},
+		{
+			source: "http",
+			program: func(t *testing.T) IProgram {
+				s := fakeHTTPReleases(t)
+				return &HTTPDownloadUntarFileProgram{
+					HTTPProgram: HTTPProgram{
+						Command:          Command{Cmd: "tool", Path: testHome(t)},
+						LatestVersionURL: s.URL + "/stable.txt",
+						DownloadURL:      s.URL + "/{VERSION}/tool-{VERSION}.tar.gz",
+						ChecksumURL:      s.URL + "/{VERSION}/SHA256SUMS",
+					},
+					Filename: "tool-{VERSION}/tool",
+				}
+			},
+			// Versions are not listed, so only the checksums are missing
+			missing: ErrChecksum,
+		},
+		{
+			source: "s3",
+			program: func(t *testing.T) IProgram {
+				objects := make(map[string]string)
+				for _, v := range []string{"1.0.0", "1.1.0"} {
+					tarball := string(testTarball(t, map[string]string{"tool": testContent(v)}))
+					objects["tool_"+v+"_"+OS+"_"+Arch+".tar.gz"] = tarball
+					objects["tool_"+v+"_SHA256SUMS"] = sha256Hex(tarball) + "  tool_" + v + "_" + OS + "_" + Arch + ".tar.gz\n"
+				}
+				s, _ := fakeMinIO(t, objects)
+				return &S3DownloadUntarFileProgram{
+					S3Program: S3Program{
+						Command:     Command{Cmd: "tool", Path: testHome(t)},
+						Bucket:      "tools",
+						Endpoint:    s.URL,
+						Region:      "us-east-1",
+						KeyRegexp:   `tool_(.+)_{OS}_{ARCH}\.tar\.gz`,
+						ChecksumKey: "tool_{VERSION}_SHA256SUMS",
+					},
+					Filename: "tool",
+				}
+			},
+			missing: ErrVersionNotFound,
+		},
+	} {
+		t.Run(tc.source, func(t *testing.T) {
+			p := tc.program(t)
+			if p.GetSource() != tc.source {
+				t.Errorf("GetSource() = %s, expected %s", p.GetSource(), tc.source)
+			}
+			assertDownload := func(download string, v string, err error, expected string) {
+				t.Helper()
+				if err != nil {
+					t.Fatalf("%s: %s", download, err)
+				}
+				if v != expected {
+					t.Errorf("%s = %s, expected %s", download, v, expected)
+				}
+				d, err := ioutil.ReadFile(p.GetFullPath())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(d) != testContent(expected) {
+					t.Errorf("%s downloaded %q", download, d)
+				}
+			}
+			v, err := p.DownloadVersion("1.0.0")
+			assertDownload("DownloadVersion(1.0.0)", v, err, "1.0.0")
+			v, err = p.DownloadLatestVersion()
+			assertDownload("DownloadLatestVersion()", v, err, "1.1.0")
+			if _, err = p.DownloadVersion("3.0.0"); !errors.Is(err, tc.missing) {
+				t.Errorf("DownloadVersion(3.0.0) returned %v, expected %v", err, tc.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/cellpointmobile/vk/file"
+)
+
+// S3Program is a Program stored in an S3 bucket, or a bucket of an S3 compatible service like MinIO
+type S3Program struct {
+	Command
+	Platform
+	Bucket      string
+	Prefix      string // Prefix of the keys of the program. Ex: tools/foo/
+	KeyRegexp   string // Regexp matching the keys of the program, the first submatch is the version. Ex: tools/foo/foo_(\d+\.\d+\.\d+)_{OS}_{ARCH}.tar.gz
+	PreRelease  bool   // Accept prereleases, which are versions with a semver prerelease part, ex: 1.2.0-rc1. Defaults to false
+	Region      string // Optional, defaults to the region of the AWS environment or config, or us-east-1
+	Endpoint    string // Optional, URL of an S3 compatible service. Ex: http://localhost:9000
+	Profile     string // Optional, profile in ~/.aws/credentials. Defaults to AWS_PROFILE or default
+	ChecksumKey string // Optional, key of SHA-256 checksums. Ex: tools/foo/foo_{VERSION}_SHA256SUMS
+}
+
+// S3DirectDownloadProgram downloads a file directly
+type S3DirectDownloadProgram struct {
+	S3Program
+}
+
+// S3DownloadUntarFileProgram downloads a tarball and extracts files from it
+type S3DownloadUntarFileProgram struct {
+	S3Program
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// S3DownloadUnzipFileProgram downloads a zip-file and extracts files from it
+type S3DownloadUnzipFileProgram struct {
+	S3Program
+	Filename string
+	Files    []file.Target // Optional, more files and directories to extract into the prefix of the program
+}
+
+// s3ListBucketResult is a page of a ListObjectsV2 response
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// getRegion returns the region of the bucket
+func (p *S3Program) getRegion() string {
+	if p.Region != "" {
+		return p.Region
+	}
+	return getAWSRegion(p.Profile)
+}
+
+// getObjectURL returns the URL of a key, or of the bucket if the key is empty
+func (p *S3Program) getObjectURL(key string) string {
+	if p.Endpoint != "" {
+		// S3 compatible services use path-style URLs
+		return strings.TrimSuffix(p.Endpoint, "/") + "/" + p.Bucket + "/" + awsEscape(key, false)
+	}
+	return "https://" + p.Bucket + ".s3." + p.getRegion() + ".amazonaws.com/" + awsEscape(key, false)
+}
+
+// authorize signs every request of the bucket, if there are AWS credentials.
+// Each request is signed right before it is sent, so retries and resumed
+// downloads get a fresh signature.
+func (p *S3Program) authorize() {
+	region := p.getRegion()
+	file.SetAuthorizer(p.getObjectURL(""), func(r *http.Request) error {
+		if c := getAWSCredentials(p.Profile); c != nil {
+			r.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+			signV4(r, c, region, "s3", time.Now())
+		}
+		return nil
+	})
+}
+
+// listKeys returns all keys starting with Prefix
+func (p *S3Program) listKeys() ([]string, error) {
+	var keys []string
+	prefix := p.replacer().Replace(p.Prefix)
+	token := ""
+	p.authorize()
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := file.Get(p.getObjectURL("")+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListBucketResult
+		if resp.StatusCode == http.StatusOK {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		} else {
+			err = fmt.Errorf("can't list s3://%s/%s: %s", p.Bucket, prefix, resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// getVersionKeys returns the keys of the program by version
+func (p *S3Program) getVersionKeys() (map[string]string, error) {
+	kr, err := regexp.Compile("^" + p.replacer().Replace(p.KeyRegexp) + "$")
+	if err != nil {
+		return nil, newError(p.Cmd, ErrVersionParse, err)
+	}
+	keys, err := p.listKeys()
+	if err != nil {
+		return nil, newError(p.Cmd, ErrVersionLookup, err)
+	}
+	versions := make(map[string]string)
+	for _, k := range keys {
+		if m := kr.FindStringSubmatch(k); len(m) > 1 {
+			versions[strings.TrimPrefix(m[1], "v")] = k
+		}
+	}
+	return versions, nil
+}
+
+// GetSource returns the type of source the program is released from
+func (p *S3Program) GetSource() string {
+	return "s3"
+}
+
+// ListVersions returns the versions of all keys matching KeyRegexp
+func (p *S3Program) ListVersions() ([]string, error) {
+	keys, err := p.getVersionKeys()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(keys))
+	for v := range keys {
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// GetLatestVersion returns the highest version in the bucket
+func (p *S3Program) GetLatestVersion() (string, string, error) {
+	keys, err := p.getVersionKeys()
+	if err != nil {
+		return "", "", err
+	}
+	versions := make([]string, 0, len(keys))
+	for v := range keys {
+		versions = append(versions, v)
+	}
+	constraint := "*"
+	if p.PreRelease {
+		constraint = ">=0.0.0-0"
+	}
+	c, _ := semver.NewConstraint(constraint)
+	v := newestVersion(versions, c)
+	if v == "" {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, errors.New("no key matches "+p.KeyRegexp))
+	}
+	return v, p.getObjectURL(keys[v]), nil
+}
+
+// GetVersion returns the given version and its download URL, if it is in the bucket
+func (p *S3Program) GetVersion(version string) (string, string, error) {
+	keys, err := p.getVersionKeys()
+	if err != nil {
+		return "", "", err
+	}
+	v := strings.TrimPrefix(version, "v")
+	k, ok := keys[v]
+	if !ok {
+		return "", "", newError(p.Cmd, ErrVersionNotFound, fmt.Errorf("no key of version %s", version))
+	}
+	return v, p.getObjectURL(k), nil
+}
+
+// getChecksum returns the SHA-256 checksum of the download URL from ChecksumKey.
+// Returns an empty string if the program has no checksums defined.
+func (p *S3Program) getChecksum(v string, u string) (string, error) {
+	if p.ChecksumKey == "" {
+		return "", nil
+	}
+	p.authorize()
+	return fetchChecksum(p.Cmd, p.getObjectURL(p.replacer("{VERSION}", v).Replace(p.ChecksumKey)), u)
+}
+
+// DownloadLatestVersion downloads the latest version and puts it into the bindir
+func (p *S3DirectDownloadProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads the given version and puts it into the bindir
+func (p *S3DirectDownloadProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *S3DirectDownloadProgram) download(v string, u string) error {
+	p.authorize()
+	return downloadFile(p, v, u)
+}
+
+// DownloadLatestVersion downloads and untars a file to the bindir
+func (p *S3DownloadUntarFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and untars the given version to the bindir
+func (p *S3DownloadUntarFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *S3DownloadUntarFileProgram) download(v string, u string) error {
+	p.authorize()
+	return extractFiles(p, v, u, file.ExtractFilesFromTar, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
+
+// DownloadLatestVersion downloads and unzips a file to the bindir
+func (p *S3DownloadUnzipFileProgram) DownloadLatestVersion() (string, error) {
+	return downloadLatest(p, p.download)
+}
+
+// DownloadVersion downloads and unzips the given version to the bindir
+func (p *S3DownloadUnzipFileProgram) DownloadVersion(version string) (string, error) {
+	return downloadVersion(p, version, p.download)
+}
+
+func (p *S3DownloadUnzipFileProgram) download(v string, u string) error {
+	p.authorize()
+	return extractFiles(p, v, u, file.ExtractFilesFromZip, p.targets(p.replacer("{VERSION}", v), p.Filename, p.Files))
+}
@@ -0,0 +1,169 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cellpointmobile/vk/file"
+)
+
+// fakeMinIO serves the objects of the bucket tools with path-style URLs, like
+// MinIO. Requests must be signed with the test credentials, and the first
+// download of each object fails halfway through.
+func fakeMinIO(t *testing.T, objects map[string]string) (*httptest.Server, *int32) {
+	c := &awsCredentials{AccessKeyID: "minio", SecretAccessKey: "minio-secret"}
+	t.Setenv("AWS_ACCESS_KEY_ID", c.AccessKeyID)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", c.SecretAccessKey)
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	var keys []string
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	delay := file.RetryDelay
+	t.Cleanup(func() { file.RetryDelay = delay })
+	file.RetryDelay = time.Millisecond
+	var requests int32
+	failed := make(map[string]bool)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// Verify the signature of the request, which must be recent
+		amzDate, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil || time.Since(amzDate) > time.Minute {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		signed, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		signed.Header.Set("X-Amz-Content-Sha256", r.Header.Get("X-Amz-Content-Sha256"))
+		signV4(signed, c, "us-east-1", "s3", amzDate)
+		if r.Header.Get("X-Amz-Content-Sha256") != emptyPayloadHash ||
+			r.Header.Get("Authorization") != signed.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+			return
+		}
+		if r.URL.Path == "/tools/" {
+			// ListObjectsV2 with pages of two keys
+			q := r.URL.Query()
+			var result struct {
+				XMLName               xml.Name `xml:"ListBucketResult"`
+				Contents              []struct{ Key string }
+				IsTruncated           bool
+				NextContinuationToken string `xml:",omitempty"`
+			}
+			start, _ := strconv.Atoi(q.Get("continuation-token"))
+			for i := start; i < len(keys); i++ {
+				if !strings.HasPrefix(keys[i], q.Get("prefix")) {
+					continue
+				}
+				if len(result.Contents) == 2 {
+					result.IsTruncated = true
+					result.NextContinuationToken = strconv.Itoa(i)
+					break
+				}
+				result.Contents = append(result.Contents, struct{ Key string }{keys[i]})
+			}
+			xml.NewEncoder(w).Encode(result)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/tools/")
+		o, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var offset int
+		if _, err = fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &offset); err == nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(o)-1, len(o)))
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, o[offset:])
+			return
+		}
+		if !failed[key] {
+			failed[key] = true
+			conn, buf, _ := w.(http.Hijacker).Hijack()
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(o), o[:len(o)/2])
+			buf.Flush()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, o)
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func TestS3(t *testing.T) {
+	tarball := testTarball(t, map[string]string{"foo": testContent("1.1.0")})
+	s, requests := fakeMinIO(t, map[string]string{
+		"foo/foo_1.0.0_" + OS + "_" + Arch + ".tar.gz":     "old",
+		"foo/foo_1.1.0_" + OS + "_" + Arch + ".tar.gz":     string(tarball),
+		"foo/foo_1.1.0_SHA256SUMS":                         sha256Hex(string(tarball)) + "  foo_1.1.0_" + OS + "_" + Arch + ".tar.gz\n",
+		"foo/foo_1.2.0-rc1_" + OS + "_" + Arch + ".tar.gz": "rc",
+		"foo/foo_1.2.0_plan9_" + Arch + ".tar.gz":          "other platform",
+		"bar/bar_2.0.0_" + OS + "_" + Arch + ".tar.gz":     "other program",
+	})
+	p := &S3DownloadUntarFileProgram{
+		S3Program: S3Program{
+			Command:     Command{Cmd: "foo", Path: testHome(t)},
+			Bucket:      "tools",
+			Endpoint:    s.URL,
+			Region:      "us-east-1",
+			Prefix:      "foo/",
+			KeyRegexp:   `foo/foo_(.+)_{OS}_{ARCH}\.tar\.gz`,
+			ChecksumKey: "foo/foo_{VERSION}_SHA256SUMS",
+		},
+		Filename: "foo",
+	}
+	versions, err := p.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(versions)
+	if expected := []string{"1.0.0", "1.1.0", "1.2.0-rc1"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("ListVersions() = %v, expected %v", versions, expected)
+	}
+	atomic.StoreInt32(requests, 0)
+	v, err := p.DownloadLatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.1.0" {
+		t.Errorf("DownloadLatestVersion() = %s, expected 1.1.0", v)
+	}
+	d, err := ioutil.ReadFile(p.GetFullPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d) != testContent("1.1.0") {
+		t.Errorf("extracted %q", d)
+	}
+	// Listing three pages, and downloading the checksums and the tarball, each resumed once
+	if *requests != 7 {
+		t.Errorf("%d requests sent, expected 7", *requests)
+	}
+}
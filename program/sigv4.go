@@ -0,0 +1,210 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 digest of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// awsCredentials are credentials for signing AWS requests
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// readINI reads a file in the INI format of the AWS config and credentials files
+func readINI(path string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return sections
+	}
+	defer f.Close()
+	var section string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if sections[section] == nil {
+				sections[section] = make(map[string]string)
+			}
+		case section != "" && strings.Contains(line, "="):
+			kv := strings.SplitN(line, "=", 2)
+			sections[section][strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return sections
+}
+
+// getAWSProfile returns the AWS profile to use, from AWS_PROFILE or the given default
+func getAWSProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// getAWSCredentials returns credentials from the AWS environment variables, or
+// from a profile in ~/.aws/credentials. Returns nil if there are no credentials.
+func getAWSCredentials(profile string) *awsCredentials {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		path = os.ExpandEnv("$HOME/.aws/credentials")
+	}
+	s, ok := readINI(path)[getAWSProfile(profile)]
+	if !ok || s["aws_access_key_id"] == "" {
+		return nil
+	}
+	return &awsCredentials{
+		AccessKeyID:     s["aws_access_key_id"],
+		SecretAccessKey: s["aws_secret_access_key"],
+		SessionToken:    s["aws_session_token"],
+	}
+}
+
+// getAWSRegion returns the region from the AWS environment variables, or from
+// a profile in ~/.aws/config. Defaults to us-east-1.
+func getAWSRegion(profile string) string {
+	for _, e := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if r := os.Getenv(e); r != "" {
+			return r
+		}
+	}
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		path = os.ExpandEnv("$HOME/.aws/config")
+	}
+	config := readINI(path)
+	profile = getAWSProfile(profile)
+	for _, section := range []string{"profile " + profile, profile} {
+		if r := config[section]["region"]; r != "" {
+			return r
+		}
+	}
+	return "us-east-1"
+}
+
+// awsEscape escapes a string as required by AWS Signature Version 4
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signV4 signs a request without a body with AWS Signature Version 4. The
+// host and all X-Amz-* headers are signed.
+func signV4(req *http.Request, c *awsCredentials, region string, service string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	// Canonical request
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(path, false),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	// String to sign and signature
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	h := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
@@ -16,8 +16,10 @@ package programs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"strings"
 
@@ -28,8 +30,15 @@ import (
 	"github.com/tidwall/gjson"
 )
 
+// Errors returned when loading definitions
+var (
+	ErrDefinitionsDownload = errors.New("could not download definitions")
+	ErrDefinitionsLoad     = errors.New("error loading definitions")
+	ErrDefinitionsParse    = errors.New("could not unmarshal definitions")
+)
+
 // LoadPrograms returns a map of programs
-func LoadPrograms(bindir string) map[string]program.IProgram {
+func LoadPrograms(bindir string) (map[string]program.IProgram, error) {
 	path := os.ExpandEnv(bindir)
 	url := viper.GetString("definitions")
 	var d []byte
@@ -42,20 +51,20 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 		cacheclient := httpcache.NewTransport(diskcache.New(definitionsCache)).Client()
 		resp, err := cacheclient.Get(url)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not download definitions: %s\n", err)
-			os.Exit(40)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsDownload, err)
 		}
 		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsDownload, resp.Status)
+		}
 		d, err = ioutil.ReadAll(resp.Body)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not download definitions: %s\n", err)
-			os.Exit(40)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsDownload, err)
 		}
 	} else {
 		d, err = ioutil.ReadFile(url)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading definitions: %s\n", err)
-			os.Exit(120)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsLoad, err)
 		}
 	}
 
@@ -63,6 +72,20 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 	untarfile := gjson.GetBytes(d, "github.untarfile")
 	unzipfile := gjson.GetBytes(d, "github.unzipfile")
 	hashicorp := gjson.GetBytes(d, "hashicorp")
+	gitlabDirectdownload := gjson.GetBytes(d, "gitlab.directdownload")
+	gitlabUntarfile := gjson.GetBytes(d, "gitlab.untarfile")
+	gitlabUnzipfile := gjson.GetBytes(d, "gitlab.unzipfile")
+	giteaDirectdownload := gjson.GetBytes(d, "gitea.directdownload")
+	giteaUntarfile := gjson.GetBytes(d, "gitea.untarfile")
+	giteaUnzipfile := gjson.GetBytes(d, "gitea.unzipfile")
+	httpDirectdownload := gjson.GetBytes(d, "http.directdownload")
+	httpUntarfile := gjson.GetBytes(d, "http.untarfile")
+	httpUnzipfile := gjson.GetBytes(d, "http.unzipfile")
+	oci := gjson.GetBytes(d, "oci")
+	s3Directdownload := gjson.GetBytes(d, "s3.directdownload")
+	s3Untarfile := gjson.GetBytes(d, "s3.untarfile")
+	s3Unzipfile := gjson.GetBytes(d, "s3.unzipfile")
+	gomodule := gjson.GetBytes(d, "gomodule")
 
 	progs := make(map[string]program.IProgram)
 
@@ -70,8 +93,7 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 		var prog program.GithubDirectDownloadProgram
 		err := json.Unmarshal([]byte(v.String()), &prog)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not unmarshal definitions: %s\n", err)
-			os.Exit(50)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
 		}
 		prog.Command.Path = path
 		progs[prog.Command.Cmd] = &prog
@@ -80,8 +102,7 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 		var prog program.GithubDownloadUntarFileProgram
 		err := json.Unmarshal([]byte(v.String()), &prog)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not unmarshal definitions: %s\n", err)
-			os.Exit(50)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
 		}
 		prog.Command.Path = path
 		progs[prog.Command.Cmd] = &prog
@@ -90,8 +111,7 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 		var prog program.GithubDownloadUnzipFileProgram
 		err := json.Unmarshal([]byte(v.String()), &prog)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not unmarshal definitions: %s\n", err)
-			os.Exit(50)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
 		}
 		prog.Command.Path = path
 		progs[prog.Command.Cmd] = &prog
@@ -100,12 +120,137 @@ func LoadPrograms(bindir string) map[string]program.IProgram {
 		var prog program.HashicorpProgram
 		err := json.Unmarshal([]byte(v.String()), &prog)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Could not unmarshal definitions: %s\n", err)
-			os.Exit(50)
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range gitlabDirectdownload.Array() {
+		var prog program.GitlabDirectDownloadProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range gitlabUntarfile.Array() {
+		var prog program.GitlabDownloadUntarFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range gitlabUnzipfile.Array() {
+		var prog program.GitlabDownloadUnzipFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range giteaDirectdownload.Array() {
+		var prog program.GiteaDirectDownloadProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range giteaUntarfile.Array() {
+		var prog program.GiteaDownloadUntarFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range giteaUnzipfile.Array() {
+		var prog program.GiteaDownloadUnzipFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range httpDirectdownload.Array() {
+		var prog program.HTTPDirectDownloadProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range httpUntarfile.Array() {
+		var prog program.HTTPDownloadUntarFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range httpUnzipfile.Array() {
+		var prog program.HTTPDownloadUnzipFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range oci.Array() {
+		var prog program.OCIProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range s3Directdownload.Array() {
+		var prog program.S3DirectDownloadProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range s3Untarfile.Array() {
+		var prog program.S3DownloadUntarFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range s3Unzipfile.Array() {
+		var prog program.S3DownloadUnzipFileProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
+		}
+		prog.Command.Path = path
+		progs[prog.Command.Cmd] = &prog
+	}
+	for _, v := range gomodule.Array() {
+		var prog program.GoModuleProgram
+		err := json.Unmarshal([]byte(v.String()), &prog)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrDefinitionsParse, err)
 		}
 		prog.Command.Path = path
 		progs[prog.Command.Cmd] = &prog
 	}
 
-	return progs
+	return progs, nil
 }
@@ -0,0 +1,189 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progress draws progress bars of downloads on a terminal. Bars of
+// concurrent downloads are drawn below each other, and redrawn in place.
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enabled turns progress bars on. They should only be enabled if Output is a
+// terminal, as they are redrawn with ANSI escape codes.
+var Enabled bool
+
+// Output is where progress bars are drawn. It is stderr, so the bars don't
+// end up in the output of vk.
+var Output io.Writer = os.Stderr
+
+// Messages is where Printf prints text
+var Messages io.Writer = os.Stdout
+
+const (
+	interval   = 200 * time.Millisecond
+	labelWidth = 16
+	barWidth   = 30
+)
+
+var (
+	mu      sync.Mutex
+	bars    []*Bar
+	lines   int  // Number of lines drawn
+	running bool // Whether bars are redrawn
+)
+
+// Bar is the progress bar of a download. All methods can be called on a nil
+// Bar, which is returned if progress bars are not enabled.
+type Bar struct {
+	current int64
+	total   int64
+	label   string
+}
+
+// IsTerminal returns true if f is a terminal, and not ex: a pipe or a file
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// New adds a progress bar of a download. Total is the size of the download,
+// or -1 if it is unknown.
+func New(label string, total int64) *Bar {
+	if !Enabled {
+		return nil
+	}
+	b := &Bar{label: label, total: total}
+	mu.Lock()
+	defer mu.Unlock()
+	bars = append(bars, b)
+	if !running {
+		running = true
+		go redraw()
+	}
+	return b
+}
+
+// Add adds n bytes to the progress of the download
+func (b *Bar) Add(n int) {
+	if b != nil {
+		atomic.AddInt64(&b.current, int64(n))
+	}
+}
+
+// Done removes the progress bar when the download has finished
+func (b *Bar) Done() {
+	if b == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, x := range bars {
+		if x == b {
+			bars = append(bars[:i], bars[i+1:]...)
+			break
+		}
+	}
+	draw("")
+}
+
+// Printf prints a line above the progress bars, so it is not overwritten by them
+func Printf(format string, a ...interface{}) {
+	if !Enabled {
+		fmt.Fprintf(Messages, format, a...)
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	draw(fmt.Sprintf(format, a...))
+}
+
+// redraw draws the progress bars until there are none
+func redraw() {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		mu.Lock()
+		if len(bars) == 0 {
+			running = false
+			mu.Unlock()
+			return
+		}
+		draw("")
+		mu.Unlock()
+	}
+}
+
+// draw replaces the drawn progress bars with text followed by the current bars
+func draw(text string) {
+	var buf bytes.Buffer
+	if lines > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", lines)
+	}
+	buf.WriteString("\r\x1b[J")
+	if text != "" {
+		Output.Write(buf.Bytes())
+		buf.Reset()
+		io.WriteString(Messages, text)
+	}
+	for _, b := range bars {
+		buf.WriteString(b.String())
+		buf.WriteByte('\n')
+	}
+	lines = len(bars)
+	Output.Write(buf.Bytes())
+}
+
+// String returns the progress bar as a line of text
+func (b *Bar) String() string {
+	label := b.label
+	if len(label) > labelWidth {
+		label = label[:labelWidth-1] + "…"
+	}
+	current := atomic.LoadInt64(&b.current)
+	if b.total <= 0 {
+		return fmt.Sprintf("%-*s %s", labelWidth, label, formatBytes(current))
+	}
+	if current > b.total {
+		current = b.total
+	}
+	filled := int(current * barWidth / b.total)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%-*s [%s] %3d%% %s / %s", labelWidth, label, bar,
+		current*100/b.total, formatBytes(current), formatBytes(b.total))
+}
+
+// formatBytes formats a number of bytes for humans, ex: 12.3 MB
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
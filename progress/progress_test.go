@@ -0,0 +1,58 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// capture redirects the bars and messages to buffers for a test
+func capture(t *testing.T, enabled bool) (*bytes.Buffer, *bytes.Buffer) {
+	output, messages := Output, Messages
+	t.Cleanup(func() { Enabled, Output, Messages = false, output, messages })
+	var o, m bytes.Buffer
+	Enabled, Output, Messages = enabled, &o, &m
+	return &o, &m
+}
+
+func TestBarsAreNotInMessages(t *testing.T) {
+	o, m := capture(t, true)
+	b := New("tool", 100)
+	b.Add(50)
+	Printf("Updating %s\n", "tool")
+	b.Done()
+	if m.String() != "Updating tool\n" {
+		t.Errorf("messages are %q", m)
+	}
+	if !strings.Contains(o.String(), "tool") || !strings.Contains(o.String(), " 50%") {
+		t.Errorf("progress bar is not drawn: %q", o)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	o, m := capture(t, false)
+	b := New("tool", 100)
+	b.Add(50)
+	Printf("Updating %s\n", "tool")
+	b.Done()
+	if b != nil || o.Len() != 0 {
+		t.Errorf("progress bar is drawn: %q", o)
+	}
+	if m.String() != "Updating tool\n" {
+		t.Errorf("messages are %q", m)
+	}
+}
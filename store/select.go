@@ -0,0 +1,84 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VersionFile is the name of files selecting tool versions for a directory.
+// Each line has a tool name and a version, ex: "kubectl 1.27.4".
+const VersionFile = ".vk-version"
+
+// toolVersionFiles are files of other tools selecting the version of a single tool
+var toolVersionFiles = map[string]string{
+	"terraform": ".terraform-version",
+}
+
+// SelectVersion walks up from dir looking for a version file selecting a
+// version of cmd. It returns the version and the file selecting it, or empty
+// strings if no file selects a version.
+func SelectVersion(dir string, cmd string) (string, string) {
+	for {
+		f := filepath.Join(dir, VersionFile)
+		if v := readVersionFile(f, cmd); v != "" {
+			return v, f
+		}
+		if name, ok := toolVersionFiles[cmd]; ok {
+			f = filepath.Join(dir, name)
+			if v := readVersionFile(f, ""); v != "" {
+				return v, f
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// readVersionFile returns the version of cmd in a version file. If cmd is
+// empty, the file is expected to only contain a version. Invalid versions are
+// ignored, see CheckVersion.
+func readVersionFile(path string, cmd string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		var v string
+		if cmd == "" {
+			v = strings.TrimPrefix(fields[0], "v")
+		} else if len(fields) >= 2 && fields[0] == cmd {
+			v = strings.TrimPrefix(fields[1], "v")
+		}
+		// A version file in a repository must not select a path outside the store
+		if v != "" && CheckVersion(v) == nil {
+			return v
+		}
+	}
+	return ""
+}
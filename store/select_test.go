@@ -0,0 +1,74 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectVersion(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "project", "src")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path string, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(root, VersionFile), "kubectl 1.26.0\nhelm v3.12.0\n")
+	write(filepath.Join(root, "project", VersionFile), "# Tools of the project\nkubectl v1.27.4\n")
+	write(filepath.Join(root, "project", ".terraform-version"), "1.5.7\n")
+	// Versions escaping the store are ignored
+	write(filepath.Join(dir, VersionFile), "kubectl ../../../../proc/self/cwd/evil\nhelm ..\n")
+	write(filepath.Join(dir, ".terraform-version"), "../evil\n")
+	for _, tc := range []struct {
+		cmd     string
+		version string
+		file    string
+	}{
+		{"kubectl", "1.27.4", filepath.Join(root, "project", VersionFile)},
+		{"helm", "3.12.0", filepath.Join(root, VersionFile)},
+		{"terraform", "1.5.7", filepath.Join(root, "project", ".terraform-version")},
+		{"k9s", "", ""},
+	} {
+		v, f := SelectVersion(dir, tc.cmd)
+		if v != tc.version || f != tc.file {
+			t.Errorf("SelectVersion(%s) = %s, %s, expected %s, %s", tc.cmd, v, f, tc.version, tc.file)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	for _, v := range []string{"", ".", "..", "../evil", "1.0.0/../../evil", `..\evil`} {
+		if err := CheckVersion(v); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("CheckVersion(%q) returned %v, expected %v", v, err, ErrInvalidVersion)
+		}
+		if _, err := GetPath("kubectl", v); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("GetPath(%q) returned %v, expected %v", v, err, ErrInvalidVersion)
+		}
+	}
+	for _, v := range []string{"1.27.4", "v1.0.0-rc.1", "1.0.0+build"} {
+		if err := CheckVersion(v); err != nil {
+			t.Errorf("CheckVersion(%q) returned %v", v, err)
+		}
+	}
+}
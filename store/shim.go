@@ -0,0 +1,78 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cellpointmobile/vk/program"
+)
+
+// shimMarker identifies shims written by vk
+const shimMarker = "# vk shim"
+
+// writeShim writes a shell script to path, which runs vk exec for cmd
+func writeShim(cmd string, path string) error {
+	vk, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	shim := fmt.Sprintf("#!/bin/sh\n%s, runs the version of %s selected by .vk-version\nexec %s exec %s \"$@\"\n",
+		shimMarker, cmd, shellQuote(vk), shellQuote(cmd))
+	return ioutil.WriteFile(path, []byte(shim), 0755)
+}
+
+// shellQuote quotes s for a shell script. Single quotes in s are ended,
+// escaped and reopened.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// resolveShim returns the stored version a shim at path runs by default, so
+// the version of a shimmed program can be checked without running vk exec
+func resolveShim(cmd string, path string) string {
+	if !IsShim(path) {
+		return ""
+	}
+	path, err := GetPath(cmd, ActiveVersion(cmd))
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func init() {
+	program.ResolveShim = resolveShim
+}
+
+// IsShim returns true if the file at path is a shim written by vk
+func IsShim(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for i := 0; i < 2 && s.Scan(); i++ {
+		if strings.HasPrefix(s.Text(), shimMarker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestShimVersion(t *testing.T) {
+	p := newFakeProgram(t)
+	defer func(shims bool) { Shims = shims }(Shims)
+	Shims = true
+	install(t, p, "1.0.0")
+	install(t, p, "2.0.0")
+	if err := Use(p, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if !IsShim(p.GetFullPath()) {
+		t.Fatalf("%s is not a shim", p.GetFullPath())
+	}
+	// The version is checked without running the shim
+	if p.GetExecutable() != filepath.Join(Dir, "fake", "1.0.0", "fake") {
+		t.Errorf("GetExecutable() = %s, expected %s", p.GetExecutable(), filepath.Join(Dir, "fake", "1.0.0", "fake"))
+	}
+	assertLocalVersion(t, p, "1.0.0")
+}
+
+func TestAdoptShim(t *testing.T) {
+	p := newFakeProgram(t)
+	defer func(shims bool) { Shims = shims }(Shims)
+	Shims = true
+	install(t, p, "1.0.0")
+	// A shim is replaced by a symlink when shims are disabled
+	Shims = false
+	if err := Adopt(p); err != nil {
+		t.Fatal(err)
+	}
+	target, err := os.Readlink(p.GetFullPath())
+	if err != nil {
+		t.Fatalf("shim is not replaced by a symlink: %s", err)
+	}
+	if target != filepath.Join(Dir, "fake", "1.0.0", "fake") {
+		t.Errorf("bindir entry links to %s, expected %s", target, filepath.Join(Dir, "fake", "1.0.0", "fake"))
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"vk", "/opt/my tools/vk", "/home/o'brien/bin/vk", `a"b$c\d`, "'"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != s {
+			t.Errorf("shellQuote(%q) is read by sh as %q", s, out)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cellpointmobile/vk/program"
+)
+
+// Dir is the directory of the versioned store. Versions of a tool are kept
+// in Dir/<tool>/<version>/<tool>, and the tool in the bindir is a symlink to
+// the active version. Other files of a tool are kept in the version directory,
+// and the files in its bin directory are symlinked into the bindir as well.
+var Dir = os.ExpandEnv("$HOME/.vk/store")
+
+// Keep is the number of versions of each tool kept in the store
+var Keep = 3
+
+// Shims makes the tool in the bindir a shim running vk exec instead of a
+// symlink, so the version can be selected per directory.
+var Shims bool
+
+// Errors returned by the store
+var (
+	ErrNoPreviousVersion = errors.New("no previous version in store")
+	ErrNotStored         = errors.New("version is not in store")
+	ErrInvalidVersion    = errors.New("invalid version")
+)
+
+// CheckVersion returns ErrInvalidVersion if a version can't be the name of a
+// directory in the store, ex: "../../bin".
+func CheckVersion(version string) error {
+	if version == "" || version == "." || version == ".." || strings.ContainsAny(version, `/\`) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidVersion, version)
+	}
+	return nil
+}
+
+// GetPath returns the path of a stored version of a command
+func GetPath(cmd string, version string) (string, error) {
+	if err := CheckVersion(version); err != nil {
+		return "", err
+	}
+	return filepath.Join(Dir, cmd, version, cmd), nil
+}
+
+// IsStored returns true if the version of the command is in the store
+func IsStored(cmd string, version string) bool {
+	path, err := GetPath(cmd, version)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// getActivePath returns the path of the file recording the active version of a command
+func getActivePath(cmd string) string {
+	return filepath.Join(Dir, cmd, "active")
+}
+
+// Active returns the version the bindir entry of a program links to, or the
+// default version of a shim. Returns an empty string if the program is not
+// installed from the store.
+func Active(p program.IProgram) string {
+	target, err := os.Readlink(p.GetFullPath())
+	if err != nil {
+		return ActiveVersion(p.GetCmd())
+	}
+	rel, err := filepath.Rel(filepath.Join(Dir, p.GetCmd()), target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.Dir(rel)
+}
+
+// ActiveVersion returns the version of a command last selected with Use
+func ActiveVersion(cmd string) string {
+	d, err := ioutil.ReadFile(getActivePath(cmd))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(d))
+}
+
+// Versions returns the stored versions of a command, most recently used first
+func Versions(cmd string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(Dir, cmd))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ModTime().After(entries[j].ModTime())
+	})
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() {
+			versions = append(versions, e.Name())
+		}
+	}
+	return versions, nil
+}
+
+// Adopt moves a program installed as a regular file in the bindir into the
+// store, and replaces it with a symlink. A shim is recreated for the version it
+// runs by default, or replaced by a symlink if shims are no longer enabled.
+// A file whose version can't be read is left in place, to be replaced by the
+// next version installed.
+func Adopt(p program.IProgram) error {
+	f := p.GetFullPath()
+	fi, err := os.Lstat(f)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	if IsShim(f) {
+		v := ActiveVersion(p.GetCmd())
+		if !IsStored(p.GetCmd(), v) {
+			// Nothing to adopt, the shim is replaced when a version is used
+			return nil
+		}
+		return Use(p, v)
+	}
+	v, err := p.GetLocalVersion()
+	var dst string
+	if err == nil {
+		dst, err = GetPath(p.GetCmd(), v)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: not moving %s into the store: %s\n", f, err)
+		return nil
+	}
+	if err = moveFile(f, dst); err != nil {
+		return err
+	}
+	return Use(p, v)
+}
+
+// Install runs download to put a version of the program into the store, see
+// Add, and switches the bindir entry to it. The bindir entry is only replaced,
+// with a single rename, once the version is in the store. Programs for another
+// platform are installed as plain files instead, see installFile.
+func Install(p program.IProgram, download func() (string, error)) (string, error) {
+	if !program.IsNative() {
+		return installFile(p, download)
+	}
+	if err := Adopt(p); err != nil {
+		return "", err
+	}
+	v, err := Add(p, download)
+	if err != nil {
+		return "", err
+	}
+	return v, Use(p, v)
+}
+
+// Add runs download with the program installed to a staging directory, and
+// moves the downloaded version into the store without using it. The bindir
+// entry of the program is left untouched.
+func Add(p program.IProgram, download func() (string, error)) (string, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return "", err
+	}
+	staging, err := ioutil.TempDir(Dir, ".staging")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+	v, err := stage(p, staging, download)
+	if err != nil {
+		return "", err
+	}
+	return v, Import(p.GetCmd(), v, filepath.Join(staging, p.GetCmd()))
+}
+
+// installFile installs a program for another platform, which vk can't run, as
+// a plain file in the bindir. The store is bypassed, so the bindir can be used
+// on other machines, and the versions vk runs are not replaced. Other files of
+// the program are kept next to it, see program.FilesPath.
+func installFile(p program.IProgram, download func() (string, error)) (string, error) {
+	f := p.GetFullPath()
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return "", err
+	}
+	staging, err := ioutil.TempDir(filepath.Dir(f), "."+p.GetCmd()+".staging")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+	v, err := stage(p, staging, download)
+	if err != nil {
+		return "", err
+	}
+	staged := filepath.Join(staging, p.GetCmd())
+	files := program.FilesPath(f)
+	if err = os.RemoveAll(files); err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(program.FilesPath(staged)); err == nil {
+		if err = os.Rename(program.FilesPath(staged), files); err != nil {
+			return "", err
+		}
+	}
+	return v, os.Rename(staged, f)
+}
+
+// stage runs download with the program installed to the staging directory
+func stage(p program.IProgram, staging string, download func() (string, error)) (string, error) {
+	bindir := filepath.Dir(p.GetFullPath())
+	p.SetPath(staging)
+	defer p.SetPath(bindir)
+	return download()
+}
+
+// Import moves a downloaded version of a command, and the files extracted
+// besides it, into the store
+func Import(cmd string, version string, path string) error {
+	dst, err := GetPath(cmd, version)
+	if err != nil {
+		return err
+	}
+	if err = moveFile(path, dst); err != nil {
+		return err
+	}
+	files := program.FilesPath(path)
+	entries, err := ioutil.ReadDir(files)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		// Replace the files of a reinstalled version
+		d := filepath.Join(filepath.Dir(dst), e.Name())
+		if err = os.RemoveAll(d); err != nil {
+			return err
+		}
+		if err = moveTree(filepath.Join(files, e.Name()), d); err != nil {
+			return err
+		}
+	}
+	return os.Remove(files)
+}
+
+// Use makes the bindir entry of a program link to a stored version. With
+// Shims, the bindir entry is a shim and the version becomes its default.
+func Use(p program.IProgram, version string) error {
+	src, err := GetPath(p.GetCmd(), version)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); err != nil {
+		return ErrNotStored
+	}
+	// The modification time of the version directory orders the store
+	now := time.Now()
+	if err := os.Chtimes(filepath.Dir(src), now, now); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(getActivePath(p.GetCmd()), []byte(version+"\n"), 0644); err != nil {
+		return err
+	}
+	tmp := p.GetFullPath() + ".link"
+	os.Remove(tmp)
+	if Shims {
+		err = writeShim(p.GetCmd(), tmp)
+	} else {
+		err = os.Symlink(src, tmp)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p.GetFullPath()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := linkBin(p, version); err != nil {
+		return err
+	}
+	return prune(p)
+}
+
+// getBinPath returns the directory of binaries of a stored version of a command
+func getBinPath(cmd string, version string) string {
+	return filepath.Join(Dir, cmd, version, "bin")
+}
+
+// isStoreLink returns true if path is a symlink into the store of a command
+func isStoreLink(path string, cmd string) bool {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Join(Dir, cmd), target)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// linkBin symlinks the binaries of a stored version of a program into the
+// bindir, replacing the links to other versions. Files in the bindir which are
+// not links into the store of the program are left alone.
+func linkBin(p program.IProgram, version string) error {
+	if err := unlinkBin(p); err != nil {
+		return err
+	}
+	bin := getBinPath(p.GetCmd(), version)
+	entries, err := ioutil.ReadDir(bin)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	bindir := filepath.Dir(p.GetFullPath())
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == p.GetCmd() {
+			continue
+		}
+		link := filepath.Join(bindir, e.Name())
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err = os.Symlink(filepath.Join(bin, e.Name()), link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlinkBin removes the symlinks to binaries of a program from the bindir
+func unlinkBin(p program.IProgram) error {
+	bindir := filepath.Dir(p.GetFullPath())
+	entries, err := ioutil.ReadDir(bindir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		link := filepath.Join(bindir, e.Name())
+		if e.Name() == p.GetCmd() || !isStoreLink(link, p.GetCmd()) {
+			continue
+		}
+		if err = os.Remove(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes a stored version of a program, and its bindir entry if it is
+// the active version.
+func Remove(p program.IProgram, version string) error {
+	path, err := GetPath(p.GetCmd(), version)
+	if err != nil {
+		return err
+	}
+	if Active(p) == version {
+		os.Remove(p.GetFullPath())
+		os.Remove(getActivePath(p.GetCmd()))
+		unlinkBin(p)
+	}
+	return os.RemoveAll(filepath.Dir(path))
+}
+
+// RemoveAll removes all stored versions of a program and its bindir entry
+func RemoveAll(p program.IProgram) error {
+	if err := os.Remove(p.GetFullPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := unlinkBin(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(Dir, p.GetCmd()))
+}
+
+// prune removes all but the Keep most recently used versions of a program.
+// The active version is never removed.
+func prune(p program.IProgram) error {
+	versions, err := Versions(p.GetCmd())
+	if err != nil {
+		return err
+	}
+	active := Active(p)
+	kept := 0
+	for _, v := range versions {
+		if v == active {
+			continue
+		}
+		kept++
+		// The active version counts as one of the kept versions
+		if kept < Keep {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(Dir, p.GetCmd(), v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback switches a program to the most recently used other version in the
+// store, which it returns. Rolling back twice switches back to the version
+// that was active before the first rollback.
+func Rollback(p program.IProgram) (string, error) {
+	if err := Adopt(p); err != nil {
+		return "", err
+	}
+	active := Active(p)
+	versions, err := Versions(p.GetCmd())
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v != active {
+			return v, Use(p, v)
+		}
+	}
+	return "", ErrNoPreviousVersion
+}
+
+// moveFile moves an executable file into place, creating directories as needed.
+// Files are copied if they can't be renamed, ex: across filesystems.
+func moveFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Copy to a temporary file, which is renamed over a running version of the
+	// command instead of overwriting it
+	out, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*.download")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if err == nil {
+		err = out.Chmod(0755)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(out.Name(), dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// moveTree moves a file or directory tree into place. Trees are copied if they
+// can't be renamed, ex: across filesystems.
+func moveTree(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, 0755)
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,262 @@
+// Copyright © 2019 Cellpoint Mobile
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cellpointmobile/vk/internal/testprogram"
+	"github.com/cellpointmobile/vk/program"
+)
+
+// newFakeProgram returns a fake program, with the store in its HOME directory
+func newFakeProgram(t *testing.T) *testprogram.Program {
+	t.Helper()
+	p := testprogram.New(t)
+	Dir = filepath.Join(filepath.Dir(p.Path), ".vk", "store")
+	return p
+}
+
+func install(t *testing.T, p *testprogram.Program, version string) {
+	t.Helper()
+	v, err := Install(p, func() (string, error) {
+		return p.DownloadVersion(version)
+	})
+	if err != nil {
+		t.Fatalf("Install(%s): %s", version, err)
+	}
+	if v != version {
+		t.Fatalf("Install(%s) = %s", version, v)
+	}
+}
+
+func assertLocalVersion(t *testing.T, p *testprogram.Program, version string) {
+	t.Helper()
+	lv, err := p.GetLocalVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lv != version {
+		t.Fatalf("local version is %s, expected %s", lv, version)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	p := newFakeProgram(t)
+	install(t, p, "1.0.0")
+	assertLocalVersion(t, p, "1.0.0")
+	target, err := os.Readlink(p.GetFullPath())
+	if err != nil {
+		t.Fatalf("bindir entry is not a symlink: %s", err)
+	}
+	if target != filepath.Join(Dir, "fake", "1.0.0", "fake") {
+		t.Errorf("bindir entry links to %s, expected %s", target, filepath.Join(Dir, "fake", "1.0.0", "fake"))
+	}
+	if Active(p) != "1.0.0" {
+		t.Errorf("Active() = %s, expected 1.0.0", Active(p))
+	}
+}
+
+func TestInstallAdopts(t *testing.T) {
+	p := newFakeProgram(t)
+	if _, err := p.DownloadVersion("1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	install(t, p, "2.0.0")
+	assertLocalVersion(t, p, "2.0.0")
+	if !IsStored("fake", "1.0.0") {
+		t.Error("version 1.0.0 installed before the store is not adopted")
+	}
+}
+
+func TestInstallReplacesUnreadableFile(t *testing.T) {
+	p := newFakeProgram(t)
+	// A truncated binary
+	if err := ioutil.WriteFile(p.GetFullPath(), []byte("\x7fELF"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	install(t, p, "1.0.0")
+	assertLocalVersion(t, p, "1.0.0")
+	versions, err := Versions("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("stored versions are %v, expected only 1.0.0", versions)
+	}
+}
+
+func TestInstallLeavesActiveVersionUntilDone(t *testing.T) {
+	p := newFakeProgram(t)
+	install(t, p, "1.0.0")
+	bindir := p.Path
+	_, err := Install(p, func() (string, error) {
+		if p.Path == bindir {
+			t.Error("download is not run in a staging directory")
+		}
+		// The active version must stay in place while downloading
+		if _, err := os.Stat(filepath.Join(bindir, "fake")); err != nil {
+			t.Errorf("bindir entry is missing while downloading: %s", err)
+		}
+		if _, err := p.DownloadVersion("2.0.0"); err != nil {
+			return "", err
+		}
+		return "", errors.New("download failed")
+	})
+	if err == nil {
+		t.Fatal("Install succeeded, expected the download error")
+	}
+	if p.Path != bindir {
+		t.Errorf("path of program is %s after Install, expected %s", p.Path, bindir)
+	}
+	assertLocalVersion(t, p, "1.0.0")
+	if IsStored("fake", "2.0.0") {
+		t.Error("failed version 2.0.0 is in the store")
+	}
+}
+
+func TestAdd(t *testing.T) {
+	p := newFakeProgram(t)
+	install(t, p, "1.0.0")
+	v, err := Add(p, func() (string, error) {
+		return p.DownloadVersion("2.0.0")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsStored("fake", v) {
+		t.Errorf("version %s is not stored", v)
+	}
+	assertLocalVersion(t, p, "1.0.0")
+}
+
+func TestAddInvalidVersion(t *testing.T) {
+	p := newFakeProgram(t)
+	_, err := Add(p, func() (string, error) {
+		return p.DownloadVersion("../../evil")
+	})
+	if !errors.Is(err, ErrInvalidVersion) {
+		t.Fatalf("Add returned %v, expected %v", err, ErrInvalidVersion)
+	}
+	if _, err = os.Stat(filepath.Join(Dir, "..", "evil")); !os.IsNotExist(err) {
+		t.Error("version is added outside the store")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	p := newFakeProgram(t)
+	if _, err := Rollback(p); err != ErrNoPreviousVersion {
+		t.Errorf("Rollback without versions returned %v, expected %v", err, ErrNoPreviousVersion)
+	}
+	install(t, p, "1.0.0")
+	install(t, p, "2.0.0")
+	for _, expected := range []string{"1.0.0", "2.0.0", "1.0.0"} {
+		v, err := Rollback(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Fatalf("Rollback() = %s, expected %s", v, expected)
+		}
+		assertLocalVersion(t, p, expected)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	p := newFakeProgram(t)
+	defer func(keep int) { Keep = keep }(Keep)
+	Keep = 2
+	for _, v := range []string{"1.0.0", "2.0.0", "3.0.0"} {
+		install(t, p, v)
+	}
+	for v, stored := range map[string]bool{"1.0.0": false, "2.0.0": true, "3.0.0": true} {
+		if IsStored("fake", v) != stored {
+			t.Errorf("IsStored(%s) = %t, expected %t", v, !stored, stored)
+		}
+	}
+	// The active version is kept, even if it is the least recently used
+	if err := Use(p, "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	install(t, p, "4.0.0")
+	if err := Use(p, "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := Versions("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0] != "2.0.0" || versions[1] != "4.0.0" {
+		t.Errorf("Versions() = %v, expected [2.0.0 4.0.0]", versions)
+	}
+}
+
+func TestInstallFiles(t *testing.T) {
+	p := newFakeProgram(t)
+	_, err := Install(p, func() (string, error) {
+		// Files extracted besides the command, ex: by a Files target
+		bin := filepath.Join(program.FilesPath(p.GetFullPath()), "bin")
+		if err := os.MkdirAll(bin, 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(bin, "fake-helper"), []byte("#!/bin/sh\n"), 0755); err != nil {
+			return "", err
+		}
+		return p.DownloadVersion("1.0.0")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(p.Path, "fake-helper")
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatalf("%s is not a symlink: %s", link, err)
+	}
+	if target != filepath.Join(getBinPath("fake", "1.0.0"), "fake-helper") {
+		t.Errorf("%s links to %s", link, target)
+	}
+	if err = RemoveAll(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("%s is not removed with the program", link)
+	}
+}
+
+func TestInstallOtherPlatform(t *testing.T) {
+	p := newFakeProgram(t)
+	install(t, p, "1.0.0")
+	defer func(goos string) { program.OS = goos }(program.OS)
+	program.OS = "plan9"
+	bindir := filepath.Join(filepath.Dir(p.Path), "plan9")
+	p.SetPath(bindir)
+	install(t, p, "2.0.0")
+	fi, err := os.Lstat(p.GetFullPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() {
+		t.Errorf("%s is not a plain file", p.GetFullPath())
+	}
+	if IsStored("fake", "2.0.0") {
+		t.Error("version for another platform is in the store")
+	}
+	// The native version is untouched
+	assertLocalVersion(t, testprogram.At(filepath.Join(filepath.Dir(p.Path), "bin")), "1.0.0")
+}